@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// RebuildConfig configures a full cache rebuild.
+type RebuildConfig struct {
+	ConfigFS     fs.FS
+	RoutesFile   string
+	Languages    []string
+	Router       http.Handler
+	Logger       *slog.Logger
+	PathExpander func(ctx context.Context, canonical string) ([]string, error) // Expands "{param}" routes into concrete paths
+}
+
+// bootstrapRoute is the subset of a routes.json entry needed for rebuilding.
+type bootstrapRoute struct {
+	Canonical string `json:"canonical"`
+	Path      string `json:"path"`
+	Handler   string `json:"handler"`
+	Strategy  string `json:"strategy"`
+}
+
+// Bootstrap renders every cacheable route through the router so the cache
+// middleware stores a fresh copy of each page.
+func (m *Manager) Bootstrap(ctx context.Context, config RebuildConfig) error {
+	data, err := fs.ReadFile(config.ConfigFS, config.RoutesFile)
+	if err != nil {
+		return fmt.Errorf("failed to read routes file: %w", err)
+	}
+
+	var routes struct {
+		Routes []bootstrapRoute `json:"routes"`
+	}
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return fmt.Errorf("failed to parse routes JSON: %w", err)
+	}
+
+	// Force existing entries to be re-rendered instead of served as hits
+	m.MarkAllStale(false)
+
+	var paths []string
+	for _, route := range routes.Routes {
+		if route.Handler == "" || route.Strategy == "" || route.Strategy == "dynamic" {
+			continue
+		}
+
+		if !strings.Contains(route.Path, "{") {
+			paths = append(paths, route.Path)
+			continue
+		}
+
+		if config.PathExpander == nil {
+			config.Logger.Warn("Skipping parameterized route without path expander",
+				slog.String("path", route.Path),
+			)
+			continue
+		}
+
+		expanded, err := config.PathExpander(ctx, route.Canonical)
+		if err != nil {
+			return fmt.Errorf("failed to expand route %s: %w", route.Canonical, err)
+		}
+		paths = append(paths, expanded...)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		status := m.render(config.Router, path)
+		if status != http.StatusOK {
+			failed++
+			config.Logger.Warn("Failed to pre-render page",
+				slog.String("path", path),
+				slog.Int("status", status),
+			)
+			continue
+		}
+		config.Logger.Debug("Pre-rendered page", slog.String("path", path))
+	}
+
+	config.Logger.Info("Pre-rendering finished",
+		slog.Int("pages", len(paths)),
+		slog.Int("failed", failed),
+	)
+
+	return nil
+}
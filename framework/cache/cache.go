@@ -0,0 +1,410 @@
+// Package cache provides a disk-backed page cache for the Statigo framework.
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaExt    = ".json"
+	contentExt = ".html.gz"
+)
+
+// revalidationKey marks internal requests that must bypass cached copies.
+type revalidationKey struct{}
+
+// WithRevalidation marks a request context as an internal re-render, so the
+// cache middleware renders a fresh copy instead of serving the cached one and
+// the request is exempt from rate limiting and threat scoring. Unlike a
+// header, the marker cannot be set by clients.
+func WithRevalidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidationKey{}, true)
+}
+
+// IsRevalidation reports whether the context belongs to an internal re-render.
+func IsRevalidation(ctx context.Context) bool {
+	revalidating, _ := ctx.Value(revalidationKey{}).(bool)
+	return revalidating
+}
+
+// Entry represents a single cached page.
+type Entry struct {
+	Key       string    `json:"key"`
+	Path      string    `json:"path"`     // Request path used to re-render the page
+	Strategy  string    `json:"strategy"` // Caching strategy the page was stored with
+	ETag      string    `json:"etag"`
+	CachedAt  time.Time `json:"cachedAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"` // Zero means the entry never expires on its own
+	Stale     bool      `json:"stale"`
+	Tags      []string  `json:"tags,omitempty"` // Dependency tags declared while rendering
+	// NoncePlaceholder is the CSP nonce placeholder rendered into Content,
+	// replaced with a fresh nonce whenever the entry is served.
+	NoncePlaceholder string `json:"noncePlaceholder,omitempty"`
+	Content          []byte `json:"-"` // Gzip-compressed response body
+}
+
+// IsStale reports whether the entry was marked stale or has passed its expiry.
+func (e *Entry) IsStale() bool {
+	if e.Stale {
+		return true
+	}
+	return !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt)
+}
+
+// Manager keeps cached pages in memory and mirrors them to disk.
+type Manager struct {
+	mu       sync.RWMutex
+	entries  map[string]*Entry
+	inflight map[string]bool            // Keys with a re-render currently running
+	tagIndex map[string]map[string]bool // Tag -> set of keys rendered with it
+	dir      string
+	router   http.Handler
+	logger   *slog.Logger
+}
+
+// NewManager creates a cache manager backed by the given directory.
+// Entries already present on disk are loaded into memory.
+func NewManager(dir string, logger *slog.Logger) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	m := &Manager{
+		entries:  make(map[string]*Entry),
+		inflight: make(map[string]bool),
+		tagIndex: make(map[string]map[string]bool),
+		dir:      dir,
+		logger:   logger,
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// SetRouter sets the handler used to re-render stale entries.
+func (m *Manager) SetRouter(router http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.router = router
+}
+
+// GetCacheKey returns the cache key for a canonical path.
+func GetCacheKey(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:16])
+}
+
+// GetDecompressedContent returns the uncompressed body of a cache entry.
+func GetDecompressedContent(entry *Entry) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(entry.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached content: %w", err)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Get returns the cache entry for a key.
+func (m *Manager) Get(key string) (*Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	return entry, ok
+}
+
+// Set stores content under the given key and persists it to disk.
+// A positive ttl makes the entry expire on its own after that duration;
+// zero keeps it until it is explicitly marked stale. noncePlaceholder is the
+// CSP nonce placeholder rendered into content, if any. Tags record what the
+// page depends on so it can be invalidated with MarkTagsStale.
+func (m *Manager) Set(key string, content []byte, strategy, path, noncePlaceholder string, ttl time.Duration, tags ...string) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(content); err != nil {
+		return fmt.Errorf("failed to compress content: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress content: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	now := time.Now()
+	entry := &Entry{
+		Key:      key,
+		Path:     path,
+		Strategy: strategy,
+		ETag:     hex.EncodeToString(sum[:8]),
+		CachedAt: now,
+		Tags:     tags,
+
+		NoncePlaceholder: noncePlaceholder,
+		Content:          buf.Bytes(),
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+
+	if err := m.writeEntry(entry); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if previous, ok := m.entries[key]; ok {
+		m.unindexTags(previous)
+	}
+	m.entries[key] = entry
+	m.indexTags(entry)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Delete removes an entry from memory and disk.
+func (m *Manager) Delete(key string) error {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if ok {
+		m.unindexTags(entry)
+		delete(m.entries, key)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	for _, ext := range []string{metaExt, contentExt} {
+		if err := os.Remove(filepath.Join(m.dir, key+ext)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cache file: %w", err)
+		}
+	}
+	return nil
+}
+
+// MarkStale marks every entry with the given strategy as stale and returns
+// how many were marked. When revalidate is true, the entries are re-rendered
+// in the background.
+func (m *Manager) MarkStale(strategy string, revalidate bool) int {
+	return m.markStale(func(e *Entry) bool { return e.Strategy == strategy }, revalidate)
+}
+
+// MarkAllStale marks every entry as stale and returns how many were marked.
+func (m *Manager) MarkAllStale(revalidate bool) int {
+	return m.markStale(func(*Entry) bool { return true }, revalidate)
+}
+
+// markStale flags matching entries as stale and optionally revalidates them.
+func (m *Manager) markStale(match func(*Entry) bool, revalidate bool) int {
+	m.mu.Lock()
+	var marked []*Entry
+	for key, entry := range m.entries {
+		if !match(entry) {
+			continue
+		}
+		// Entries are shared with readers, so replace rather than mutate
+		staleEntry := *entry
+		staleEntry.Stale = true
+		m.unindexTags(entry)
+		m.entries[key] = &staleEntry
+		m.indexTags(&staleEntry)
+		marked = append(marked, &staleEntry)
+	}
+	router := m.router
+	m.mu.Unlock()
+
+	// Persist the flags without holding the lock, as Set does. An entry
+	// rendered anew in the meantime has written its own metadata.
+	for _, entry := range marked {
+		m.mu.RLock()
+		current := m.entries[entry.Key] == entry
+		m.mu.RUnlock()
+		if !current {
+			continue
+		}
+		if err := m.writeMeta(entry); err != nil {
+			m.logger.Warn("Failed to persist stale flag",
+				slog.String("key", entry.Key),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	if revalidate && router != nil && len(marked) > 0 {
+		go m.revalidate(router, marked)
+	}
+
+	return len(marked)
+}
+
+// Revalidate re-renders a single entry in the background. It returns false
+// without doing anything when a re-render for the key is already running
+// or no router is set.
+func (m *Manager) Revalidate(key, path string) bool {
+	m.mu.RLock()
+	router := m.router
+	m.mu.RUnlock()
+
+	if router == nil || !m.claim(key) {
+		return false
+	}
+
+	go func() {
+		defer m.release(key)
+		if status := m.render(router, path); status != http.StatusOK {
+			m.logger.Warn("Cache revalidation failed",
+				slog.String("path", path),
+				slog.Int("status", status),
+			)
+		}
+	}()
+
+	return true
+}
+
+// revalidate re-renders the given entries through the router so the cache
+// middleware replaces them. Entries already being re-rendered are skipped.
+func (m *Manager) revalidate(router http.Handler, entries []*Entry) {
+	rendered := 0
+	for _, entry := range entries {
+		if !m.claim(entry.Key) {
+			continue
+		}
+		status := m.render(router, entry.Path)
+		m.release(entry.Key)
+
+		if status != http.StatusOK {
+			m.logger.Warn("Cache revalidation failed",
+				slog.String("path", entry.Path),
+				slog.Int("status", status),
+			)
+			continue
+		}
+		rendered++
+	}
+	m.logger.Info("Cache revalidation completed", slog.Int("pages", rendered))
+}
+
+// claim marks a key as being re-rendered. It returns false if it already is.
+func (m *Manager) claim(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inflight[key] {
+		return false
+	}
+	m.inflight[key] = true
+	return true
+}
+
+// release clears the in-flight marker set by claim.
+func (m *Manager) release(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.inflight, key)
+}
+
+// render performs an internal GET request for path and returns the status code.
+func (m *Manager) render(router http.Handler, path string) int {
+	req, err := http.NewRequestWithContext(WithRevalidation(context.Background()), http.MethodGet, path, nil)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+
+	rec := &discardWriter{header: make(http.Header), status: http.StatusOK}
+	router.ServeHTTP(rec, req)
+	return rec.status
+}
+
+// discardWriter is a minimal http.ResponseWriter that only records the status.
+type discardWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+// writeEntry persists an entry's content and metadata to disk.
+func (m *Manager) writeEntry(entry *Entry) error {
+	contentPath := filepath.Join(m.dir, entry.Key+contentExt)
+	if err := os.WriteFile(contentPath, entry.Content, 0644); err != nil {
+		return fmt.Errorf("failed to write cache content: %w", err)
+	}
+	return m.writeMeta(entry)
+}
+
+// writeMeta persists an entry's metadata to disk.
+func (m *Manager) writeMeta(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+	metaPath := filepath.Join(m.dir, entry.Key+metaExt)
+	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	return nil
+}
+
+// load reads all persisted entries from the cache directory.
+func (m *Manager) load() error {
+	metaFiles, err := filepath.Glob(filepath.Join(m.dir, "*"+metaExt))
+	if err != nil {
+		return fmt.Errorf("failed to list cache directory: %w", err)
+	}
+
+	for _, metaPath := range metaFiles {
+		data, err := os.ReadFile(metaPath)
+		if err != nil {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			m.logger.Warn("Skipping corrupt cache metadata", slog.String("file", metaPath))
+			continue
+		}
+
+		contentPath := strings.TrimSuffix(metaPath, metaExt) + contentExt
+		if entry.Content, err = os.ReadFile(contentPath); err != nil {
+			continue
+		}
+
+		m.entries[entry.Key] = &entry
+		m.indexTags(&entry)
+	}
+
+	m.logger.Debug("Loaded cache entries from disk", slog.Int("count", len(m.entries)))
+	return nil
+}
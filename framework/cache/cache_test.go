@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestMarkTagsStalePersistsFlags(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	m, err := NewManager(dir, logger)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	pages := []struct {
+		key  string
+		tags []string
+	}{
+		{key: "post", tags: []string{"post:hello", "category:go"}},
+		{key: "list", tags: []string{"posts"}},
+		{key: "about", tags: nil},
+	}
+	for _, p := range pages {
+		if err := m.Set(p.key, []byte("<p>"+p.key+"</p>"), "immutable", "/"+p.key, "", 0, p.tags...); err != nil {
+			t.Fatalf("Set(%s): %v", p.key, err)
+		}
+	}
+
+	if got := m.MarkTagsStale(false, "post:hello", "posts"); got != 2 {
+		t.Fatalf("MarkTagsStale = %d, want 2", got)
+	}
+
+	// The flags must survive a restart
+	reloaded, err := NewManager(dir, logger)
+	if err != nil {
+		t.Fatalf("NewManager (reload): %v", err)
+	}
+	want := map[string]bool{"post": true, "list": true, "about": false}
+	for key, stale := range want {
+		for name, manager := range map[string]*Manager{"memory": m, "disk": reloaded} {
+			entry, ok := manager.Get(key)
+			if !ok {
+				t.Fatalf("%s: entry %s missing", name, key)
+			}
+			if entry.Stale != stale {
+				t.Errorf("%s: entry %s Stale = %v, want %v", name, key, entry.Stale, stale)
+			}
+		}
+	}
+}
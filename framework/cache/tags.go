@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"log/slog"
+	"sort"
+)
+
+// PathTag returns the tag every entry carries for its own canonical path,
+// so a single page can be invalidated by path.
+func PathTag(canonical string) string {
+	return "path:" + canonical
+}
+
+// MarkTagsStale marks every entry rendered with any of the given tags as
+// stale and returns how many were marked. When revalidate is true, the
+// entries are re-rendered in the background.
+func (m *Manager) MarkTagsStale(revalidate bool, tags ...string) int {
+	m.mu.RLock()
+	keys := m.keysForTags(tags)
+	m.mu.RUnlock()
+
+	return m.markStale(func(e *Entry) bool { return keys[e.Key] }, revalidate)
+}
+
+// PurgeTags removes every entry rendered with any of the given tags from
+// memory and disk and returns how many were removed. Unlike MarkTagsStale,
+// purged pages are not served again until they are rendered anew.
+func (m *Manager) PurgeTags(tags ...string) int {
+	m.mu.RLock()
+	keys := m.keysForTags(tags)
+	m.mu.RUnlock()
+
+	purged := 0
+	for key := range keys {
+		if err := m.Delete(key); err != nil {
+			m.logger.Warn("Failed to purge cache entry",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+// CachedPage identifies a cache entry and the page it was rendered from.
+type CachedPage struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+}
+
+// PagesForTags returns the pages rendered with any of the given tags,
+// sorted by path. It is the set MarkTagsStale would mark.
+func (m *Manager) PagesForTags(tags ...string) []CachedPage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := m.keysForTags(tags)
+	pages := make([]CachedPage, 0, len(keys))
+	for key := range keys {
+		if entry, ok := m.entries[key]; ok {
+			pages = append(pages, CachedPage{Key: key, Path: entry.Path})
+		}
+	}
+	sortPages(pages)
+	return pages
+}
+
+// AllPages returns every cached page, sorted by path.
+func (m *Manager) AllPages() []CachedPage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pages := make([]CachedPage, 0, len(m.entries))
+	for key, entry := range m.entries {
+		pages = append(pages, CachedPage{Key: key, Path: entry.Path})
+	}
+	sortPages(pages)
+	return pages
+}
+
+// sortPages orders pages by path, then key.
+func sortPages(pages []CachedPage) {
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].Path != pages[j].Path {
+			return pages[i].Path < pages[j].Path
+		}
+		return pages[i].Key < pages[j].Key
+	})
+}
+
+// keysForTags returns the set of keys tagged with any of the given tags.
+// The caller must hold m.mu.
+func (m *Manager) keysForTags(tags []string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, tag := range tags {
+		for key := range m.tagIndex[tag] {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// indexTags adds an entry's tags to the tag index. The caller must hold m.mu.
+func (m *Manager) indexTags(entry *Entry) {
+	for _, tag := range entry.Tags {
+		keys, ok := m.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]bool)
+			m.tagIndex[tag] = keys
+		}
+		keys[entry.Key] = true
+	}
+}
+
+// unindexTags removes an entry's tags from the tag index. The caller must hold m.mu.
+func (m *Manager) unindexTags(entry *Entry) {
+	for _, tag := range entry.Tags {
+		keys := m.tagIndex[tag]
+		delete(keys, entry.Key)
+		if len(keys) == 0 {
+			delete(m.tagIndex, tag)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"statigo/framework/security"
+)
+
+// BansCommandConfig contains configuration for the bans command.
+type BansCommandConfig struct {
+	BanListFile string   // Path to banned-ips.json
+	LockFile    string   // Lock held by whoever owns the ban list
+	SocketPath  string   // Admin socket of a running server
+	Allowlist   []string // Never-ban IPs and ranges (used when no server is running)
+	Logger      *slog.Logger
+}
+
+// NewBansCommand creates the bans command for managing the IP ban list.
+// When the server is running, changes go through its admin socket;
+// otherwise the ban list file is edited directly under a lock.
+func NewBansCommand(config BansCommandConfig) *Command {
+	return &Command{
+		Name: "bans",
+		Desc: "Manage banned IPs",
+		Subcommands: []*Command{
+			bansListCommand(config),
+			bansAddCommand(config),
+			bansRemoveCommand(config),
+			bansImportCommand(config),
+			bansExportCommand(config),
+			bansPruneCommand(config),
+		},
+	}
+}
+
+// withBanAdmin runs fn with the ban list of the running server or the file.
+func withBanAdmin(config BansCommandConfig, fn func(admin security.BanAdmin) error) error {
+	admin, closeAdmin, err := openBanAdmin(config)
+	if err != nil {
+		return err
+	}
+	defer closeAdmin()
+
+	return fn(admin)
+}
+
+// openBanAdmin connects to the running server, or opens the ban list file
+// directly under a lock if no server is listening.
+func openBanAdmin(config BansCommandConfig) (security.BanAdmin, func(), error) {
+	if client, err := security.DialAdmin(config.SocketPath); err == nil {
+		return client, func() {}, nil
+	}
+
+	unlock, err := security.LockFile(config.LockFile)
+	if errors.Is(err, security.ErrLocked) {
+		return nil, nil, fmt.Errorf("ban list is in use by a running server, but its admin socket %s is not reachable", config.SocketPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	banList, err := security.NewIPBanList(config.BanListFile, config.Logger)
+	if err != nil {
+		unlock()
+		return nil, nil, fmt.Errorf("failed to open ban list: %w", err)
+	}
+	if err := banList.SetAllowlist(config.Allowlist); err != nil {
+		banList.Stop()
+		unlock()
+		return nil, nil, err
+	}
+
+	return security.LocalBanAdmin{BanList: banList}, func() {
+		banList.Stop()
+		unlock()
+	}, nil
+}
+
+// bansListCommand prints active bans as a table or JSON.
+func bansListCommand(config BansCommandConfig) *Command {
+	cmd := &Command{
+		Name:    "list",
+		Aliases: []string{"ls"},
+		Desc:    "List active bans",
+		Args:    NoArgs,
+	}
+	asJSON := cmd.Flags().Bool("json", false, "Print bans as JSON")
+	reason := cmd.Flags().String("reason", "", "Only bans whose reason contains this `text`")
+	since := cmd.Flags().String("since", "", "Only bans newer than a `duration` (24h) or date (2006-01-02)")
+
+	cmd.Run = func(args []string) error {
+		var after time.Time
+		if *since != "" {
+			if d, err := time.ParseDuration(*since); err == nil {
+				after = time.Now().Add(-d)
+			} else if t, err := time.Parse(time.DateOnly, *since); err == nil {
+				after = t
+			} else {
+				return Usagef("invalid --since value %q", *since)
+			}
+		}
+
+		var entries []*security.BanEntry
+		err := withBanAdmin(config, func(admin security.BanAdmin) error {
+			var err error
+			entries, err = admin.List()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		filtered := make([]*security.BanEntry, 0, len(entries))
+		for _, entry := range entries {
+			if *reason != "" && !strings.Contains(strings.ToLower(entry.Reason), strings.ToLower(*reason)) {
+				continue
+			}
+			if !after.IsZero() && entry.BannedAt.Before(after) {
+				continue
+			}
+			filtered = append(filtered, entry)
+		}
+
+		if *asJSON {
+			return writeBansJSON(os.Stdout, filtered)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "IP\tREASON\tBANNED AT\tEXPIRES")
+		for _, entry := range filtered {
+			expires := "never"
+			if !entry.ExpiresAt.IsZero() {
+				expires = entry.ExpiresAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.IP, entry.Reason, entry.BannedAt.Local().Format(time.DateTime), expires)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d ban(s)\n", len(filtered))
+		return nil
+	}
+	return cmd
+}
+
+// bansAddCommand bans an IP or CIDR range.
+func bansAddCommand(config BansCommandConfig) *Command {
+	cmd := &Command{
+		Name:    "add",
+		Aliases: []string{"ban"},
+		Desc:    "Ban an IP or range",
+		Usage:   "<ip|cidr>",
+		Args:    ExactArgs(1),
+	}
+	reason := cmd.Flags().String("reason", "Manual ban", "Reason for the ban")
+	ttl := cmd.Flags().Duration("ttl", 0, "Ban duration (e.g. 24h); 0 bans permanently")
+
+	cmd.Run = func(args []string) error {
+		entry := security.BanEntry{
+			IP:       args[0],
+			Reason:   *reason,
+			BannedAt: time.Now(),
+		}
+		if *ttl > 0 {
+			entry.ExpiresAt = entry.BannedAt.Add(*ttl)
+		}
+
+		err := withBanAdmin(config, func(admin security.BanAdmin) error {
+			return admin.Ban(entry)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Banned %s\n", args[0])
+		return nil
+	}
+	return cmd
+}
+
+// bansRemoveCommand removes a ban.
+func bansRemoveCommand(config BansCommandConfig) *Command {
+	return &Command{
+		Name:    "remove",
+		Aliases: []string{"rm", "unban"},
+		Desc:    "Remove a ban",
+		Usage:   "<ip|cidr>",
+		Args:    ExactArgs(1),
+		Run: func(args []string) error {
+			err := withBanAdmin(config, func(admin security.BanAdmin) error {
+				return admin.Unban(args[0])
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Unbanned %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+// bansImportCommand adds bans from a JSON file in the banned-ips.json format.
+func bansImportCommand(config BansCommandConfig) *Command {
+	return &Command{
+		Name:  "import",
+		Desc:  "Import bans from JSON (- reads stdin)",
+		Usage: "<file|->",
+		Args:  ExactArgs(1),
+		Run: func(args []string) error {
+			var data []byte
+			var err error
+			if args[0] == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read bans: %w", err)
+			}
+
+			var entries []*security.BanEntry
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return fmt.Errorf("failed to parse bans: %w", err)
+			}
+
+			var imported int
+			err = withBanAdmin(config, func(admin security.BanAdmin) error {
+				imported, err = admin.Import(entries)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported %d of %d ban(s)\n", imported, len(entries))
+			return nil
+		},
+	}
+}
+
+// bansExportCommand writes active bans as JSON to a file or stdout.
+func bansExportCommand(config BansCommandConfig) *Command {
+	return &Command{
+		Name:  "export",
+		Desc:  "Export bans as JSON (to stdout without a file)",
+		Usage: "[file]",
+		Args:  MaxArgs(1),
+		Run: func(args []string) error {
+			var entries []*security.BanEntry
+			err := withBanAdmin(config, func(admin security.BanAdmin) error {
+				var err error
+				entries, err = admin.List()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 || args[0] == "-" {
+				return writeBansJSON(os.Stdout, entries)
+			}
+
+			file, err := os.Create(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to create export file: %w", err)
+			}
+			defer file.Close()
+
+			if err := writeBansJSON(file, entries); err != nil {
+				return err
+			}
+			fmt.Printf("Exported %d ban(s) to %s\n", len(entries), args[0])
+			return nil
+		},
+	}
+}
+
+// bansPruneCommand removes expired bans.
+func bansPruneCommand(config BansCommandConfig) *Command {
+	return &Command{
+		Name: "prune",
+		Desc: "Remove expired bans",
+		Args: NoArgs,
+		Run: func(args []string) error {
+			var pruned int
+			err := withBanAdmin(config, func(admin security.BanAdmin) error {
+				var err error
+				pruned, err = admin.Prune()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Pruned %d expired ban(s)\n", pruned)
+			return nil
+		},
+	}
+}
+
+// writeBansJSON writes ban entries as indented JSON.
+func writeBansJSON(w io.Writer, entries []*security.BanEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return fmt.Errorf("failed to encode bans: %w", err)
+	}
+	return nil
+}
@@ -2,27 +2,134 @@
 package cli
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
 )
 
-// Command represents a CLI command.
+// Exit codes returned by Run.
+const (
+	ExitOK      = 0
+	ExitFailure = 1 // The command failed
+	ExitUsage   = 2 // Unknown command, invalid flags or arguments
+)
+
+// Command represents a CLI command. A command either runs itself or
+// dispatches to one of its subcommands.
 type Command struct {
-	Name    string
-	Aliases []string
-	Desc    string
-	Run     func() error
+	Name        string
+	Aliases     []string
+	Desc        string
+	Usage       string                    // Positional arguments shown in help, e.g. "<ip|cidr>"
+	Args        func(args []string) error // Validates positional arguments (optional)
+	Subcommands []*Command
+	Run         func(args []string) error // Receives the positional arguments after flag parsing
+
+	flags *flag.FlagSet
+}
+
+// Flags returns the command's flag set. Flags may appear before or after
+// positional arguments.
+func (cmd *Command) Flags() *flag.FlagSet {
+	if cmd.flags == nil {
+		cmd.flags = flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+		cmd.flags.SetOutput(io.Discard)
+	}
+	return cmd.flags
+}
+
+// subcommand returns the subcommand with the given name or alias.
+func (cmd *Command) subcommand(name string) *Command {
+	for _, sub := range cmd.Subcommands {
+		if sub.Name == name || slices.Contains(sub.Aliases, name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// UsageError reports invalid command-line usage.
+type UsageError struct {
+	Command string // Command path, e.g. "statigo bans add"
+	Msg     string
+}
+
+func (e *UsageError) Error() string {
+	return e.Msg
+}
+
+// Usagef returns a UsageError, for commands validating their own arguments.
+func Usagef(format string, args ...any) error {
+	return &UsageError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ExitError makes Run exit with a specific code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// NoArgs rejects positional arguments.
+func NoArgs(args []string) error {
+	if len(args) > 0 {
+		return Usagef("unexpected argument: %s", args[0])
+	}
+	return nil
+}
+
+// ExactArgs requires exactly n positional arguments.
+func ExactArgs(n int) func(args []string) error {
+	return RangeArgs(n, n)
+}
+
+// MaxArgs allows at most n positional arguments.
+func MaxArgs(n int) func(args []string) error {
+	return RangeArgs(0, n)
+}
+
+// RangeArgs requires between minArgs and maxArgs positional arguments.
+func RangeArgs(minArgs, maxArgs int) func(args []string) error {
+	return func(args []string) error {
+		switch {
+		case len(args) < minArgs:
+			return Usagef("expected %d argument(s), got %d", minArgs, len(args))
+		case len(args) > maxArgs:
+			return Usagef("unexpected argument: %s", args[maxArgs])
+		}
+		return nil
+	}
 }
 
 // CLI manages command-line interface.
 type CLI struct {
+	Name     string // Program name shown in help
 	commands map[string]*Command
+	stdout   io.Writer
+	stderr   io.Writer
 }
 
 // New creates a new CLI instance.
 func New() *CLI {
 	return &CLI{
+		Name:     filepath.Base(os.Args[0]),
 		commands: make(map[string]*Command),
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
 	}
 }
 
@@ -37,67 +144,227 @@ func (c *CLI) Register(cmd *Command) {
 	}
 }
 
-// Execute runs a command by name.
+// IsCommand reports whether args (without the program name) select a
+// registered command or help, rather than starting the server.
+func (c *CLI) IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, exists := c.commands[args[0]]
+	return exists || isHelp(args[0]) || args[0] == "help"
+}
+
+// Run executes the command selected by args, prints any error and returns
+// the process exit code.
+func (c *CLI) Run(args []string) int {
+	err := c.Execute(args)
+	if err == nil {
+		return ExitOK
+	}
+
+	fmt.Fprintf(c.stderr, "Error: %v\n", err)
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(c.stderr, "Run '%s --help' for usage.\n", usageErr.Command)
+		return ExitUsage
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
+
+// Execute runs the command selected by args.
 func (c *CLI) Execute(args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("no command specified")
+		return &UsageError{Command: c.Name, Msg: "no command specified"}
 	}
 
-	cmdName := args[0]
-	cmd, exists := c.commands[cmdName]
+	if args[0] == "help" || isHelp(args[0]) {
+		return c.help(args[1:])
+	}
+
+	cmd, exists := c.commands[args[0]]
 	if !exists {
-		return fmt.Errorf("unknown command: %s", cmdName)
+		return &UsageError{Command: c.Name, Msg: "unknown command: " + args[0]}
 	}
+	return c.run(cmd, c.Name+" "+cmd.Name, args[1:])
+}
 
-	return cmd.Run()
+// run parses flags and arguments for cmd and runs it, or dispatches to a
+// subcommand. path is the command line leading to cmd.
+func (c *CLI) run(cmd *Command, path string, args []string) error {
+	if len(cmd.Subcommands) > 0 {
+		if len(args) == 0 {
+			return &UsageError{Command: path, Msg: "no subcommand specified"}
+		}
+		if isHelp(args[0]) {
+			c.printCommandHelp(cmd, path)
+			return nil
+		}
+		sub := cmd.subcommand(args[0])
+		if sub == nil {
+			return &UsageError{Command: path, Msg: "unknown subcommand: " + args[0]}
+		}
+		return c.run(sub, path+" "+sub.Name, args[1:])
+	}
+
+	positional, err := parseInterspersed(cmd.Flags(), args)
+	if errors.Is(err, flag.ErrHelp) {
+		c.printCommandHelp(cmd, path)
+		return nil
+	}
+	if err != nil {
+		return &UsageError{Command: path, Msg: err.Error()}
+	}
+
+	if cmd.Args != nil {
+		err = cmd.Args(positional)
+	}
+	if err == nil {
+		err = cmd.Run(positional)
+	}
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) && usageErr.Command == "" {
+		usageErr.Command = path
+	}
+	return err
 }
 
-// PrintHelp prints available commands.
-func (c *CLI) PrintHelp() {
-	fmt.Println("Available commands:")
+// help prints help for the command named by args, or for all commands.
+func (c *CLI) help(args []string) error {
+	if len(args) == 0 {
+		c.PrintHelp()
+		return nil
+	}
+
+	cmd, exists := c.commands[args[0]]
+	if !exists {
+		return &UsageError{Command: c.Name, Msg: "unknown command: " + args[0]}
+	}
+	path := c.Name + " " + cmd.Name
+	for _, name := range args[1:] {
+		sub := cmd.subcommand(name)
+		if sub == nil {
+			return &UsageError{Command: path, Msg: "unknown subcommand: " + name}
+		}
+		cmd, path = sub, path+" "+sub.Name
+	}
 
-	// Track which commands we've printed to avoid duplicates from aliases
-	printed := make(map[string]bool)
+	c.printCommandHelp(cmd, path)
+	return nil
+}
 
+// PrintHelp prints available commands.
+func (c *CLI) PrintHelp() {
+	var commands []*Command
 	for name, cmd := range c.commands {
-		if name == cmd.Name && !printed[cmd.Name] {
-			aliasStr := ""
-			if len(cmd.Aliases) > 0 {
-				aliasStr = fmt.Sprintf(" (aliases: %v)", cmd.Aliases)
-			}
-			fmt.Printf("  %s%s - %s\n", cmd.Name, aliasStr, cmd.Desc)
-			printed[cmd.Name] = true
+		if name == cmd.Name {
+			commands = append(commands, cmd)
 		}
 	}
+
+	fmt.Fprintf(c.stdout, "Usage: %s [command]\n\n", c.Name)
+	fmt.Fprintln(c.stdout, "Without a command, the server is started.")
+	fmt.Fprintln(c.stdout)
+	fmt.Fprintln(c.stdout, "Commands:")
+	printCommands(c.stdout, commands)
+	fmt.Fprintf(c.stdout, "\nRun '%s help <command>' for more information on a command.\n", c.Name)
 }
 
-// ParseCommand parses command line arguments and determines if a command is being run.
-func ParseCommand(args []string) (string, bool) {
-	if len(args) < 2 {
-		return "", false
+// printCommandHelp prints usage, subcommands and flags of a command.
+func (c *CLI) printCommandHelp(cmd *Command, path string) {
+	usage := path
+	if len(cmd.Subcommands) > 0 {
+		usage += " <subcommand>"
+	}
+	if hasFlags(cmd) {
+		usage += " [options]"
+	}
+	if cmd.Usage != "" {
+		usage += " " + cmd.Usage
+	}
+
+	fmt.Fprintf(c.stdout, "Usage: %s\n", usage)
+	if cmd.Desc != "" {
+		fmt.Fprintf(c.stdout, "\n%s\n", cmd.Desc)
+	}
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(c.stdout, "\nAliases: %s\n", strings.Join(cmd.Aliases, ", "))
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		fmt.Fprintln(c.stdout, "\nSubcommands:")
+		printCommands(c.stdout, cmd.Subcommands)
+		fmt.Fprintf(c.stdout, "\nRun '%s <subcommand> --help' for more information on a subcommand.\n", path)
 	}
 
-	return args[1], true
+	if hasFlags(cmd) {
+		fmt.Fprintln(c.stdout, "\nOptions:")
+		tw := tabwriter.NewWriter(c.stdout, 0, 0, 3, ' ', 0)
+		cmd.flags.VisitAll(func(f *flag.Flag) {
+			name, usage := flag.UnquoteUsage(f)
+			line := "  --" + f.Name
+			if name != "" {
+				line += " " + name
+			}
+			switch f.DefValue {
+			case "", "0", "false", "0s":
+			default:
+				usage += fmt.Sprintf(" (default %q)", f.DefValue)
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", line, usage)
+		})
+		tw.Flush()
+	}
 }
 
-// ShouldRunCommand checks if we should run a command instead of starting the server.
-func ShouldRunCommand() bool {
-	cmd, exists := ParseCommand(os.Args)
-	if !exists {
-		return false
+// printCommands prints commands sorted by name.
+func printCommands(w io.Writer, commands []*Command) {
+	sorted := append([]*Command(nil), commands...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, cmd := range sorted {
+		desc := cmd.Desc
+		if len(cmd.Aliases) > 0 {
+			desc += " (aliases: " + strings.Join(cmd.Aliases, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Name, desc)
 	}
+	tw.Flush()
+}
 
-	// List of known commands and aliases
-	knownCommands := map[string]bool{
-		"prerender":   true,
-		"pre-render":  true,
-		"bake":        true,
-		"warm":        true,
-		"prepare":     true,
-		"cache-all":   true,
-		"clear-cache": true,
-		"invalidate":  true,
+// parseInterspersed parses flags that may appear before or after positional
+// arguments and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
+}
+
+// hasFlags reports whether the command defines any flags.
+func hasFlags(cmd *Command) bool {
+	count := 0
+	if cmd.flags != nil {
+		cmd.flags.VisitAll(func(*flag.Flag) { count++ })
+	}
+	return count > 0
+}
 
-	return knownCommands[cmd]
+// isHelp reports whether arg asks for help.
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
 }
@@ -10,35 +10,55 @@ import (
 	"path/filepath"
 
 	"statigo/framework/cache"
+	"statigo/framework/middleware"
+	"statigo/framework/router"
 )
 
-// PrerenderCommandConfig contains configuration for the prerender command.
-type PrerenderCommandConfig struct {
-	ConfigFS     fs.FS
-	RoutesFile   string
-	Languages    []string
+// Site is what the commands that render pages need from the application.
+// Commands receive a function that builds it, so the router and content
+// source are only set up when such a command actually runs.
+type Site struct {
 	Router       http.Handler
-	CacheManager *cache.Manager
-	Logger       *slog.Logger
+	CacheManager *cache.Manager // nil when the disk cache is disabled
+	Routes       *router.Registry
+	Redirects    *middleware.RedirectRegistry
 	PathExpander func(ctx context.Context, canonical string) ([]string, error)
 }
 
+// PrerenderCommandConfig contains configuration for the prerender command.
+type PrerenderCommandConfig struct {
+	ConfigFS   fs.FS
+	RoutesFile string
+	Languages  []string
+	Site       func() (*Site, error)
+	Logger     *slog.Logger
+}
+
 // NewPrerenderCommand creates a new prerender command.
 func NewPrerenderCommand(config PrerenderCommandConfig) *Command {
 	return &Command{
 		Name:    "prerender",
 		Aliases: []string{"pre-render", "bake", "warm", "prepare", "cache-all"},
 		Desc:    "Pre-render and cache all cacheable pages",
-		Run: func() error {
+		Args:    NoArgs,
+		Run: func(args []string) error {
+			site, err := config.Site()
+			if err != nil {
+				return err
+			}
+			if site.CacheManager == nil {
+				return fmt.Errorf("disk cache is disabled in dev mode")
+			}
+
 			config.Logger.Info("Starting cache pre-rendering...")
 
-			if err := config.CacheManager.Bootstrap(context.Background(), cache.RebuildConfig{
+			if err := site.CacheManager.Bootstrap(context.Background(), cache.RebuildConfig{
 				ConfigFS:     config.ConfigFS,
 				RoutesFile:   config.RoutesFile,
 				Languages:    config.Languages,
-				Router:       config.Router,
+				Router:       site.Router,
 				Logger:       config.Logger,
-				PathExpander: config.PathExpander,
+				PathExpander: site.PathExpander,
 			}); err != nil {
 				return fmt.Errorf("pre-rendering failed: %w", err)
 			}
@@ -61,7 +81,8 @@ func NewClearCacheCommand(config ClearCacheCommandConfig) *Command {
 		Name:    "clear-cache",
 		Aliases: []string{"invalidate"},
 		Desc:    "Clear all cached files",
-		Run: func() error {
+		Args:    NoArgs,
+		Run: func(args []string) error {
 			config.Logger.Info("Clearing cache...", slog.String("dir", config.CacheDir))
 
 			// Check if cache directory exists
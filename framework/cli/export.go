@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"statigo/framework/export"
+)
+
+// ExportCommandConfig contains configuration for the export command.
+type ExportCommandConfig struct {
+	Site     func() (*Site, error)
+	StaticFS fs.FS
+	Files    []string // Non-page paths to export, e.g. "/sitemap.xml"
+	Logger   *slog.Logger
+}
+
+// NewExportCommand creates the export command, which writes the whole site
+// as static files.
+func NewExportCommand(config ExportCommandConfig) *Command {
+	cmd := &Command{
+		Name: "export",
+		Desc: "Export the site as static files for hosting without the server",
+		Args: NoArgs,
+	}
+	out := cmd.Flags().String("out", "./dist", "Output `directory`")
+
+	cmd.Run = func(args []string) error {
+		site, err := config.Site()
+		if err != nil {
+			return err
+		}
+
+		config.Logger.Info("Exporting static site...", slog.String("out", *out))
+
+		result, err := export.Run(context.Background(), export.Config{
+			OutDir:       *out,
+			Router:       site.Router,
+			Routes:       site.Routes,
+			PathExpander: site.PathExpander,
+			StaticFS:     config.StaticFS,
+			Files:        config.Files,
+			Redirects:    site.Redirects,
+			Logger:       config.Logger,
+		})
+		if err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+
+		config.Logger.Info("Static export finished",
+			slog.String("out", *out),
+			slog.Int("pages", result.Pages),
+			slog.Int("files", result.Files),
+			slog.Int("assets", result.Assets),
+			slog.Int("redirects", result.Redirects),
+			slog.Int("failed", result.Failed),
+		)
+		if result.Failed > 0 {
+			return fmt.Errorf("%d path(s) failed to export", result.Failed)
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SnapshotCommandConfig contains configuration for the snapshot command.
+type SnapshotCommandConfig struct {
+	Pull   func(ctx context.Context) (int, error) // Refreshes the snapshot, returning the number of responses stored
+	Logger *slog.Logger
+}
+
+// NewSnapshotCommand creates the snapshot command, which manages the offline
+// snapshot of the content API.
+func NewSnapshotCommand(config SnapshotCommandConfig) *Command {
+	return &Command{
+		Name: "snapshot",
+		Desc: "Manage the offline snapshot of the content API",
+		Subcommands: []*Command{
+			{
+				Name: "pull",
+				Desc: "Fetch all content from the API into the snapshot",
+				Args: NoArgs,
+				Run: func(args []string) error {
+					config.Logger.Info("Pulling content snapshot...")
+
+					stored, err := config.Pull(context.Background())
+					if err != nil {
+						return fmt.Errorf("snapshot pull failed after storing %d response(s): %w", stored, err)
+					}
+
+					config.Logger.Info("Content snapshot updated", slog.Int("responses", stored))
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"statigo/framework/security"
+	"statigo/framework/webhook"
+)
+
+// WebhooksCommandConfig contains configuration for the webhooks command.
+type WebhooksCommandConfig struct {
+	OpenLog    func() (*webhook.Log, error) // Opens the delivery log (used when no server is running)
+	SocketPath string                       // Admin socket of a running server
+	Replay     func(id string, dryRun bool) (webhook.Delivery, error)
+}
+
+// webhookAdmin is the set of delivery log operations used by the command.
+type webhookAdmin interface {
+	List() ([]webhook.Delivery, error)
+	Replay(id string, dryRun bool) (webhook.Delivery, error)
+}
+
+// localWebhookAdmin reads the delivery log and replays deliveries in-process.
+type localWebhookAdmin struct {
+	config WebhooksCommandConfig
+}
+
+func (a localWebhookAdmin) List() ([]webhook.Delivery, error) {
+	log, err := a.config.OpenLog()
+	if err != nil {
+		return nil, err
+	}
+	return log.List()
+}
+
+func (a localWebhookAdmin) Replay(id string, dryRun bool) (webhook.Delivery, error) {
+	return a.config.Replay(id, dryRun)
+}
+
+// socketWebhookAdmin goes through a running server's admin socket, so
+// replays invalidate the server's in-memory cache.
+type socketWebhookAdmin struct {
+	client *security.AdminClient
+}
+
+func (a socketWebhookAdmin) List() ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	err := a.client.Call(http.MethodGet, "/webhooks", nil, &deliveries)
+	return deliveries, err
+}
+
+func (a socketWebhookAdmin) Replay(id string, dryRun bool) (webhook.Delivery, error) {
+	var delivery webhook.Delivery
+	path := fmt.Sprintf("/webhooks/replay?id=%s&dryRun=%t", url.QueryEscape(id), dryRun)
+	err := a.client.Call(http.MethodPost, path, nil, &delivery)
+	return delivery, err
+}
+
+// NewWebhooksCommand creates the webhooks command for inspecting and
+// replaying received webhook deliveries.
+func NewWebhooksCommand(config WebhooksCommandConfig) *Command {
+	return &Command{
+		Name: "webhooks",
+		Desc: "Inspect and replay received webhooks",
+		Subcommands: []*Command{
+			webhooksListCommand(config),
+			webhooksShowCommand(config),
+			webhooksReplayCommand(config),
+		},
+	}
+}
+
+// openWebhookAdmin uses the running server's admin socket if it is
+// reachable, and the delivery log otherwise.
+func openWebhookAdmin(config WebhooksCommandConfig) webhookAdmin {
+	if client, err := security.DialAdmin(config.SocketPath); err == nil {
+		return socketWebhookAdmin{client: client}
+	}
+	return localWebhookAdmin{config: config}
+}
+
+// webhooksListCommand prints deliveries as a table or JSON.
+func webhooksListCommand(config WebhooksCommandConfig) *Command {
+	cmd := &Command{
+		Name:    "list",
+		Aliases: []string{"ls"},
+		Desc:    "List received deliveries, newest first",
+		Args:    NoArgs,
+	}
+	asJSON := cmd.Flags().Bool("json", false, "Print deliveries as JSON")
+	limit := cmd.Flags().Int("limit", 20, "Maximum number of deliveries to show (0 for all)")
+
+	cmd.Run = func(args []string) error {
+		deliveries, err := openWebhookAdmin(config).List()
+		if err != nil {
+			return err
+		}
+		if *limit > 0 && *limit < len(deliveries) {
+			deliveries = deliveries[:*limit]
+		}
+
+		if *asJSON {
+			return writeJSON(os.Stdout, deliveries)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tRECEIVED AT\tEVENT\tENTITY\tSTATUS\tINVALIDATED\tDURATION")
+		for _, d := range deliveries {
+			status := d.Status
+			if d.ReplayOf != "" {
+				status += " (replay of " + d.ReplayOf + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%.1fms\n",
+				d.ID, d.ReceivedAt.Local().Format(time.DateTime), d.Event, d.Entity, status, d.Invalidated, d.DurationMs)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d delivery(s)\n", len(deliveries))
+		return nil
+	}
+	return cmd
+}
+
+// webhooksShowCommand prints a single delivery as JSON.
+func webhooksShowCommand(config WebhooksCommandConfig) *Command {
+	return &Command{
+		Name:  "show",
+		Desc:  "Print a delivery with its payload",
+		Usage: "<id>",
+		Args:  ExactArgs(1),
+		Run: func(args []string) error {
+			deliveries, err := openWebhookAdmin(config).List()
+			if err != nil {
+				return err
+			}
+			for _, d := range deliveries {
+				if d.ID == args[0] {
+					return writeJSON(os.Stdout, d)
+				}
+			}
+			return fmt.Errorf("%w: %s", webhook.ErrNotFound, args[0])
+		},
+	}
+}
+
+// webhooksReplayCommand processes a delivery again, or reports what it would invalidate.
+func webhooksReplayCommand(config WebhooksCommandConfig) *Command {
+	cmd := &Command{
+		Name:  "replay",
+		Desc:  "Process a delivery again",
+		Usage: "<id>",
+		Args:  ExactArgs(1),
+	}
+	dryRun := cmd.Flags().Bool("dry-run", false, "Only report the cache entries that would be invalidated")
+
+	cmd.Run = func(args []string) error {
+		delivery, err := openWebhookAdmin(config).Replay(args[0], *dryRun)
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "KEY\tPATH")
+			for _, page := range delivery.Pages {
+				fmt.Fprintf(tw, "%s\t%s\n", page.Key, page.Path)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("Would invalidate %d cache page(s)\n", delivery.Invalidated)
+			return nil
+		}
+
+		fmt.Printf("Replayed %s as %s: %s, invalidated %d cache page(s)\n",
+			args[0], delivery.ID, delivery.Status, delivery.Invalidated)
+		return nil
+	}
+	return cmd
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
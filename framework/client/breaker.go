@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	apperrors "statigo/framework/errors"
+	"statigo/framework/health"
+)
+
+// BreakerConfig configures the per-host circuit breaker. After
+// FailureThreshold consecutive failures the circuit opens and requests to
+// the host fail immediately. Once CoolDown has passed, trial requests are let
+// through one at a time; HalfOpenSuccesses successful trials close the
+// circuit again, a failed one reopens it.
+type BreakerConfig struct {
+	FailureThreshold  int // Zero disables the breaker
+	CoolDown          time.Duration
+	HalfOpenSuccesses int
+}
+
+// DefaultBreakerConfig returns sensible default configuration.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold:  5,
+		CoolDown:          30 * time.Second,
+		HalfOpenSuccesses: 1,
+	}
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Requests pass through
+	BreakerOpen                         // Requests fail immediately
+	BreakerHalfOpen                     // Trial requests probe the host
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerStatus describes the circuit breaker of one host.
+type BreakerStatus struct {
+	Host      string    `json:"host"`
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	OpenedAt  time.Time `json:"openedAt,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// errNoOutcome is recorded for requests canceled by the caller. They say
+// nothing about the host, so they leave the counters alone.
+var errNoOutcome = errors.New("request canceled by caller")
+
+// breaker is the circuit breaker of one host.
+type breaker struct {
+	host   string
+	config BreakerConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int  // Consecutive failures
+	successes int  // Successful trials while half-open
+	trial     bool // A trial request is in flight
+	openedAt  time.Time
+	lastError string
+}
+
+// allow reports whether a request may be sent. If so, the returned function
+// must be called with the request's failure, nil on success, or
+// errNoOutcome if the request was canceled.
+func (b *breaker) allow() (func(failure error), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.config.CoolDown {
+			return nil, false
+		}
+		b.successes = 0
+		b.transition(BreakerHalfOpen)
+	}
+	if b.state == BreakerHalfOpen {
+		if b.trial {
+			return nil, false
+		}
+		b.trial = true
+		return func(failure error) { b.record(failure, true) }, true
+	}
+	return func(failure error) { b.record(failure, false) }, true
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *breaker) record(failure error, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(failure, errNoOutcome) {
+		// Let another trial through; the canceled one proved nothing
+		if trial {
+			b.trial = false
+		}
+		return
+	}
+
+	if failure != nil {
+		b.failures++
+		b.lastError = failure.Error()
+	}
+
+	switch {
+	case trial:
+		b.trial = false
+		if failure != nil {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenSuccesses {
+			b.failures = 0
+			b.transition(BreakerClosed)
+		}
+	case b.state == BreakerClosed:
+		if failure == nil {
+			b.failures = 0
+		} else if b.failures >= b.config.FailureThreshold {
+			b.open()
+		}
+	}
+	// Requests let through before the circuit opened only update the counters
+}
+
+func (b *breaker) open() {
+	b.openedAt = time.Now()
+	b.transition(BreakerOpen)
+}
+
+// transition changes the state and logs the change.
+func (b *breaker) transition(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+
+	switch state {
+	case BreakerOpen:
+		b.logger.Warn("Circuit breaker opened",
+			slog.String("host", b.host),
+			slog.String("from", from.String()),
+			slog.Int("failures", b.failures),
+			slog.Duration("cool_down", b.config.CoolDown),
+			slog.String("last_error", b.lastError),
+		)
+	default:
+		b.logger.Info("Circuit breaker "+state.String(),
+			slog.String("host", b.host),
+			slog.String("from", from.String()),
+		)
+	}
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Host:      b.host,
+		State:     b.state.String(),
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+	if b.state != BreakerClosed {
+		status.OpenedAt = b.openedAt
+	}
+	return status
+}
+
+// breakerFor returns the breaker of host, or nil when breakers are disabled.
+func (c *Client) breakerFor(host string) *breaker {
+	if c.config.Breaker.FailureThreshold <= 0 {
+		return nil
+	}
+
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &breaker{host: host, config: c.config.Breaker, logger: c.logger}
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// acquire asks the breaker of host for permission to send a request. The
+// returned function records the outcome of the request.
+func (c *Client) acquire(host string) (func(failure error), error) {
+	b := c.breakerFor(host)
+	if b == nil {
+		return func(error) {}, nil
+	}
+	done, ok := b.allow()
+	if !ok {
+		return nil, apperrors.NewCircuitOpenError(host)
+	}
+	return done, nil
+}
+
+// requestFailure returns the failure a response counts as for the breaker:
+// transport errors, 5xx and 429 responses. Requests canceled by the caller
+// say nothing about the host and return errNoOutcome.
+func requestFailure(ctx context.Context, resp *http.Response, err error) error {
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return errNoOutcome
+		}
+		return err
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Breakers returns the state of the circuit breaker of every host the
+// client has talked to, sorted by host.
+func (c *Client) Breakers() []BreakerStatus {
+	c.breakersMu.Lock()
+	breakers := make([]*breaker, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		breakers = append(breakers, b)
+	}
+	c.breakersMu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Host < statuses[j].Host })
+	return statuses
+}
+
+// HealthCheck returns a health check reporting the client's circuit
+// breakers: down while a circuit is open, degraded while one is half-open.
+func (c *Client) HealthCheck(name string) health.CheckFunc {
+	return func(ctx context.Context) health.CheckResult {
+		result := health.CheckResult{Name: name, Status: "up"}
+		breakers := c.Breakers()
+		for _, status := range breakers {
+			switch status.State {
+			case BreakerOpen.String():
+				result.Status = "down"
+				result.Error = fmt.Sprintf("circuit open for %s: %s", status.Host, status.LastError)
+			case BreakerHalfOpen.String():
+				if result.Status == "up" {
+					result.Status = "degraded"
+				}
+			}
+		}
+		if len(breakers) > 0 {
+			result.Details = breakers
+		}
+		return result
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	errHost := errors.New("connection refused")
+
+	// A step asks the breaker for permission and, if granted, records the
+	// outcome. wait lets the cool-down pass first; hold keeps the request
+	// in flight so the next step runs concurrently with it.
+	type step struct {
+		wait      bool
+		hold      bool
+		failure   error
+		wantAllow bool
+		wantState BreakerState
+	}
+	const (
+		closed   = BreakerClosed
+		open     = BreakerOpen
+		halfOpen = BreakerHalfOpen
+	)
+
+	tests := []struct {
+		name              string
+		halfOpenSuccesses int
+		steps             []step
+	}{
+		{
+			name: "opens after consecutive failures",
+			steps: []step{
+				{failure: errHost, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wantAllow: false, wantState: open},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{failure: errHost, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: closed},
+				{wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: closed},
+			},
+		},
+		{
+			name: "successful trial closes",
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wait: true, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: closed},
+			},
+		},
+		{
+			name: "failed trial reopens",
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wait: true, failure: errHost, wantAllow: true, wantState: open},
+				{wantAllow: false, wantState: open},
+			},
+		},
+		{
+			name: "one trial at a time",
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wait: true, hold: true, wantAllow: true, wantState: halfOpen},
+				{wantAllow: false, wantState: halfOpen},
+			},
+		},
+		{
+			name:              "several successful trials needed",
+			halfOpenSuccesses: 2,
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wait: true, wantAllow: true, wantState: halfOpen},
+				{wantAllow: true, wantState: closed},
+			},
+		},
+		{
+			name: "canceled trial lets another through",
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true, wantState: open},
+				{wait: true, failure: errNoOutcome, wantAllow: true, wantState: halfOpen},
+				{wantAllow: true, wantState: closed},
+			},
+		},
+		{
+			name: "canceled requests do not count",
+			steps: []step{
+				{failure: errHost, wantAllow: true},
+				{failure: errHost, wantAllow: true},
+				{failure: errNoOutcome, wantAllow: true, wantState: closed},
+				{failure: errNoOutcome, wantAllow: true, wantState: closed},
+				{failure: errHost, wantAllow: true, wantState: open},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			halfOpenSuccesses := tt.halfOpenSuccesses
+			if halfOpenSuccesses == 0 {
+				halfOpenSuccesses = 1
+			}
+			b := &breaker{
+				host: "api.example.com",
+				config: BreakerConfig{
+					FailureThreshold:  3,
+					CoolDown:          time.Minute,
+					HalfOpenSuccesses: halfOpenSuccesses,
+				},
+				logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			for i, s := range tt.steps {
+				if s.wait {
+					b.mu.Lock()
+					b.openedAt = b.openedAt.Add(-b.config.CoolDown)
+					b.mu.Unlock()
+				}
+
+				done, ok := b.allow()
+				if ok != s.wantAllow {
+					t.Fatalf("step %d: allow = %v, want %v", i, ok, s.wantAllow)
+				}
+				if ok && !s.hold {
+					done(s.failure)
+				}
+
+				if got := b.status().State; got != s.wantState.String() {
+					t.Fatalf("step %d: state = %s, want %s", i, got, s.wantState)
+				}
+			}
+		})
+	}
+}
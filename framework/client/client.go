@@ -5,14 +5,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
+
+	apperrors "statigo/framework/errors"
 )
 
+// maxErrorBodyBytes limits how much of an error response is kept in the error.
+const maxErrorBodyBytes = 512
+
 // Config holds HTTP client configuration.
 type Config struct {
 	BaseURL         string
@@ -26,6 +36,7 @@ type Config struct {
 	BearerToken     string
 	UserAgent       string
 	Headers         map[string]string
+	Breaker         BreakerConfig // Per-host circuit breaker
 }
 
 // DefaultConfig returns sensible default configuration.
@@ -39,6 +50,7 @@ func DefaultConfig() Config {
 		RetryWaitMin:    1 * time.Second,
 		RetryWaitMax:    30 * time.Second,
 		UserAgent:       "Statigo/1.0",
+		Breaker:         DefaultBreakerConfig(),
 	}
 }
 
@@ -47,6 +59,9 @@ type Client struct {
 	httpClient *http.Client
 	config     Config
 	logger     *slog.Logger
+
+	breakersMu sync.Mutex
+	breakers   map[string]*breaker // Keyed by host
 }
 
 // New creates a new HTTP client.
@@ -63,40 +78,55 @@ func New(config Config, logger *slog.Logger) *Client {
 		ResponseHeaderTimeout: config.Timeout,
 	}
 
-	return &Client{
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout:   config.Timeout,
 			Transport: transport,
 		},
-		config: config,
-		logger: logger,
+		config:   config,
+		logger:   logger,
+		breakers: make(map[string]*breaker),
 	}
+
+	// Track the base host from the start so its breaker shows up in health checks
+	if u, err := url.Parse(config.BaseURL); err == nil && u.Host != "" {
+		c.breakerFor(u.Host)
+	}
+	return c
 }
 
 // Get performs a GET request and decodes the JSON response.
-func (c *Client) Get(ctx context.Context, path string, result interface{}) error {
-	return c.doJSON(ctx, http.MethodGet, path, nil, result)
+func (c *Client) Get(ctx context.Context, path string, result interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodGet, path, nil, result, opts)
 }
 
 // Post performs a POST request with a JSON body and decodes the response.
-func (c *Client) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
-	return c.doJSON(ctx, http.MethodPost, path, body, result)
+// It is only retried when sent WithIdempotencyKey.
+func (c *Client) Post(ctx context.Context, path string, body interface{}, result interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodPost, path, body, result, opts)
 }
 
 // Put performs a PUT request with a JSON body and decodes the response.
-func (c *Client) Put(ctx context.Context, path string, body interface{}, result interface{}) error {
-	return c.doJSON(ctx, http.MethodPut, path, body, result)
+func (c *Client) Put(ctx context.Context, path string, body interface{}, result interface{}, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodPut, path, body, result, opts)
 }
 
 // Delete performs a DELETE request.
-func (c *Client) Delete(ctx context.Context, path string) error {
-	return c.doJSON(ctx, http.MethodDelete, path, nil, nil)
+func (c *Client) Delete(ctx context.Context, path string, opts ...RequestOption) error {
+	return c.doJSON(ctx, http.MethodDelete, path, nil, nil, opts)
 }
 
-// doJSON performs an HTTP request with JSON encoding/decoding.
-func (c *Client) doJSON(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+// doJSON performs an HTTP request with JSON encoding/decoding. Idempotent
+// requests are retried on network errors, 5xx and 429 responses, waiting as
+// long as the server's Retry-After asks or a jittered exponential backoff.
+// Failures are returned as *apperrors.AppError: network and timeout errors
+// when no response arrived, HTTP errors for 4xx/5xx responses and parse
+// errors for undecodable bodies. A canceled ctx returns ctx.Err().
+func (c *Client) doJSON(ctx context.Context, method, path string, body interface{}, result interface{}, opts []RequestOption) error {
 	url := c.config.BaseURL + path
+	options := c.requestOptions(opts)
 
+	// Requests built from a bytes.Reader can recreate their body per attempt
 	var bodyReader io.Reader
 	if body != nil {
 		bodyBytes, err := json.Marshal(body)
@@ -127,66 +157,159 @@ func (c *Client) doJSON(ctx context.Context, method, path string, body interface
 		req.Header.Set(key, value)
 	}
 
+	if options.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", options.idempotencyKey)
+	}
+
+	maxRetries := 0
+	if options.retryable(method) {
+		maxRetries = options.maxRetries
+	}
+
 	// Perform request with retries
 	var resp *http.Response
+	var respBody []byte
 	var lastErr error
-
-	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Calculate backoff
-			wait := c.config.RetryWaitMin * time.Duration(1<<uint(attempt-1))
-			if wait > c.config.RetryWaitMax {
-				wait = c.config.RetryWaitMax
-			}
-
-			c.logger.Debug("retrying request",
-				slog.String("method", method),
-				slog.String("url", url),
-				slog.Int("attempt", attempt),
-				slog.Duration("wait", wait),
-			)
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(wait):
-			}
+	attempt := 0
+	for {
+		resp, respBody, lastErr = c.send(req, options)
+		if apperrors.IsCircuitOpenError(lastErr) {
+			// Fail fast without retrying while the host's circuit is open
+			return lastErr
+		}
+		if lastErr == nil && !retryableStatus(resp.StatusCode) {
+			break
+		}
+		if attempt >= maxRetries || ctx.Err() != nil {
+			break
 		}
 
-		resp, lastErr = c.httpClient.Do(req)
-		if lastErr == nil && resp.StatusCode < 500 {
+		attempt++
+		wait, ok := c.backoff(attempt, resp)
+		if !ok {
+			// The server asked to wait longer than we are willing to
 			break
 		}
 
-		if resp != nil {
-			resp.Body.Close()
+		c.logger.Debug("retrying request",
+			slog.String("method", method),
+			slog.String("url", url),
+			slog.Int("attempt", attempt),
+			slog.Duration("wait", wait),
+		)
+
+		select {
+		case <-ctx.Done():
+			return classifyRequestError(ctx, ctx.Err(), method, path, attempt)
+		case <-time.After(wait):
 		}
 	}
 
 	if lastErr != nil {
-		return fmt.Errorf("request failed after %d retries: %w", c.config.MaxRetries, lastErr)
+		return classifyRequestError(ctx, lastErr, method, path, attempt+1)
 	}
-	defer resp.Body.Close()
 
 	// Check status code
 	if resp.StatusCode >= 400 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return &HTTPError{
-			StatusCode: resp.StatusCode,
-			Body:       string(bodyBytes),
+		message := fmt.Sprintf("%s %s returned %d", method, path, resp.StatusCode)
+		if detail := bytes.TrimSpace(respBody[:min(len(respBody), maxErrorBodyBytes)]); len(detail) > 0 {
+			message += ": " + string(detail)
 		}
+		return apperrors.NewHTTPError(resp.StatusCode, message)
 	}
 
-	// Decode response
+	// Decode response; an empty body is not a valid result
 	if result != nil {
-		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return apperrors.NewParseError(err, fmt.Sprintf("failed to decode response of %s %s", method, path))
 		}
 	}
 
 	return nil
 }
 
+// send performs one attempt of req with a fresh body and reads the whole
+// response, so the attempt's timeout can be released.
+func (c *Client) send(req *http.Request, options requestOptions) (*http.Response, []byte, error) {
+	ctx := req.Context()
+	attemptCtx := ctx
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	attemptReq := req.Clone(attemptCtx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		attemptReq.Body = body
+	}
+
+	done, err := c.acquire(req.URL.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := c.httpClient.Do(attemptReq)
+	if err != nil {
+		done(requestFailure(ctx, nil, err))
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		done(requestFailure(ctx, nil, err))
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	done(requestFailure(ctx, resp, nil))
+	return resp, body, nil
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// backoff returns how long to wait before retry number attempt. A
+// Retry-After header on the previous response is honored; it reports false
+// when that exceeds RetryWaitMax. Otherwise the wait is drawn at random up to
+// the exponential backoff ("full jitter"), so clients don't retry in step.
+func (c *Client) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait, wait <= c.config.RetryWaitMax
+		}
+	}
+
+	ceiling := c.config.RetryWaitMin << min(attempt-1, 30)
+	if ceiling <= 0 || ceiling > c.config.RetryWaitMax {
+		ceiling = c.config.RetryWaitMax
+	}
+	if ceiling <= 0 {
+		return 0, true
+	}
+	return rand.N(ceiling + 1), true
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
 // Do performs a raw HTTP request.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	// Set default headers
@@ -198,16 +321,26 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		req.Header.Set("Authorization", "Bearer "+c.config.BearerToken)
 	}
 
-	return c.httpClient.Do(req)
+	done, err := c.acquire(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	done(requestFailure(req.Context(), resp, err))
+	return resp, err
 }
 
-// HTTPError represents an HTTP error response.
-type HTTPError struct {
-	StatusCode int
-	Body       string
-}
+// classifyRequestError converts an error from a request that got no
+// response into a timeout or network AppError.
+func classifyRequestError(ctx context.Context, err error, method, path string, attempts int) error {
+	if ctx.Err() == context.Canceled {
+		return ctx.Err()
+	}
 
-// Error implements the error interface.
-func (e *HTTPError) Error() string {
-	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+	message := fmt.Sprintf("%s %s failed after %d attempt(s)", method, path, attempts)
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return apperrors.NewTimeoutError(err, message)
+	}
+	return apperrors.NewNetworkError(err, message)
 }
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestOption overrides the client configuration for a single request.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	maxRetries     int
+	timeout        time.Duration // Per attempt; zero leaves only the client's Timeout
+	idempotencyKey string
+}
+
+// WithRetries sets how many times the request may be retried. Requests
+// that are not idempotent are only retried with an idempotency key.
+func WithRetries(n int) RequestOption {
+	return func(o *requestOptions) {
+		o.maxRetries = max(n, 0)
+	}
+}
+
+// WithTimeout bounds each attempt of the request. The client's Timeout
+// still applies, so it can only shorten it.
+func WithTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = d
+	}
+}
+
+// WithIdempotencyKey sends key in the Idempotency-Key header, letting the
+// API deduplicate the request. This makes POST and PATCH requests safe to
+// retry, which they otherwise are not.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// requestOptions returns the options for a request, starting from the
+// client configuration.
+func (c *Client) requestOptions(opts []RequestOption) requestOptions {
+	o := requestOptions{maxRetries: c.config.MaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// retryable reports whether a request may be sent more than once without
+// side effects being applied twice.
+func (o requestOptions) retryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return o.idempotencyKey != ""
+}
@@ -3,6 +3,8 @@ package context
 
 import (
 	gocontext "context"
+	"sync"
+	"time"
 )
 
 // ContextKey is a custom type for context keys to avoid collisions.
@@ -14,7 +16,12 @@ const (
 	CanonicalPathKey ContextKey = "canonicalPath"
 	PageTitleKey     ContextKey = "pageTitle"
 	StrategyKey      ContextKey = "cacheStrategy"
+	IntervalKey      ContextKey = "cacheInterval"
+	CacheTagsKey     ContextKey = "cacheTags"
 	LayoutDataKey    ContextKey = "layoutData"
+	ClientIPKey      ContextKey = "clientIP"
+	SchemeKey        ContextKey = "scheme"
+	DegradedKey      ContextKey = "degraded"
 )
 
 // GetLanguage retrieves the language from context.
@@ -69,6 +76,107 @@ func SetStrategy(ctx gocontext.Context, strategy string) gocontext.Context {
 	return gocontext.WithValue(ctx, StrategyKey, strategy)
 }
 
+// GetInterval retrieves the cache revalidation interval from context.
+func GetInterval(ctx gocontext.Context) time.Duration {
+	if interval, ok := ctx.Value(IntervalKey).(time.Duration); ok {
+		return interval
+	}
+	return 0
+}
+
+// SetInterval creates a new context with the cache revalidation interval set.
+func SetInterval(ctx gocontext.Context, interval time.Duration) gocontext.Context {
+	return gocontext.WithValue(ctx, IntervalKey, interval)
+}
+
+// cacheTags collects the cache tags declared while rendering a request.
+type cacheTags struct {
+	mu   sync.Mutex
+	tags []string
+	seen map[string]bool
+}
+
+// WithCacheTags creates a new context that collects cache tags declared
+// by handlers further down the chain.
+func WithCacheTags(ctx gocontext.Context) gocontext.Context {
+	return gocontext.WithValue(ctx, CacheTagsKey, &cacheTags{seen: make(map[string]bool)})
+}
+
+// AddCacheTags declares cache tags the current page depends on.
+// It does nothing when the context isn't collecting tags.
+func AddCacheTags(ctx gocontext.Context, tags ...string) {
+	collector, ok := ctx.Value(CacheTagsKey).(*cacheTags)
+	if !ok {
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, tag := range tags {
+		if tag == "" || collector.seen[tag] {
+			continue
+		}
+		collector.seen[tag] = true
+		collector.tags = append(collector.tags, tag)
+	}
+}
+
+// GetCacheTags retrieves the cache tags declared so far.
+func GetCacheTags(ctx gocontext.Context) []string {
+	collector, ok := ctx.Value(CacheTagsKey).(*cacheTags)
+	if !ok {
+		return nil
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	return append([]string(nil), collector.tags...)
+}
+
+// degradedMark records why a response was rendered from fallback content.
+type degradedMark struct {
+	mu     sync.Mutex
+	reason string
+}
+
+// WithDegraded creates a new context that records whether handlers further
+// down the chain rendered fallback content. An existing recorder is kept, so
+// every middleware that asked for one sees the mark.
+func WithDegraded(ctx gocontext.Context) gocontext.Context {
+	if _, ok := ctx.Value(DegradedKey).(*degradedMark); ok {
+		return ctx
+	}
+	return gocontext.WithValue(ctx, DegradedKey, &degradedMark{})
+}
+
+// MarkDegraded records that the response uses fallback content, e.g. a
+// snapshot of an unavailable API. It does nothing when the context isn't
+// recording.
+func MarkDegraded(ctx gocontext.Context, reason string) {
+	mark, ok := ctx.Value(DegradedKey).(*degradedMark)
+	if !ok {
+		return
+	}
+
+	mark.mu.Lock()
+	defer mark.mu.Unlock()
+	if mark.reason == "" {
+		mark.reason = reason
+	}
+}
+
+// GetDegraded returns the reason the response was marked degraded, or "".
+func GetDegraded(ctx gocontext.Context) string {
+	mark, ok := ctx.Value(DegradedKey).(*degradedMark)
+	if !ok {
+		return ""
+	}
+
+	mark.mu.Lock()
+	defer mark.mu.Unlock()
+	return mark.reason
+}
+
 // GetLayoutData retrieves the layout data from context.
 func GetLayoutData(ctx gocontext.Context) interface{} {
 	return ctx.Value(LayoutDataKey)
@@ -78,3 +186,29 @@ func GetLayoutData(ctx gocontext.Context) interface{} {
 func SetLayoutData(ctx gocontext.Context, data interface{}) gocontext.Context {
 	return gocontext.WithValue(ctx, LayoutDataKey, data)
 }
+
+// GetClientIP retrieves the resolved client IP from context.
+func GetClientIP(ctx gocontext.Context) string {
+	if ip, ok := ctx.Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return ""
+}
+
+// SetClientIP creates a new context with the resolved client IP set.
+func SetClientIP(ctx gocontext.Context, ip string) gocontext.Context {
+	return gocontext.WithValue(ctx, ClientIPKey, ip)
+}
+
+// GetScheme retrieves the resolved request scheme ("http" or "https") from context.
+func GetScheme(ctx gocontext.Context) string {
+	if scheme, ok := ctx.Value(SchemeKey).(string); ok {
+		return scheme
+	}
+	return ""
+}
+
+// SetScheme creates a new context with the resolved request scheme set.
+func SetScheme(ctx gocontext.Context, scheme string) gocontext.Context {
+	return gocontext.WithValue(ctx, SchemeKey, scheme)
+}
@@ -15,6 +15,7 @@ const (
 	ErrorTypeHTTP           ErrorType = "HTTP"
 	ErrorTypeParse          ErrorType = "PARSE"
 	ErrorTypeRetryExhausted ErrorType = "RETRY_EXHAUSTED"
+	ErrorTypeCircuitOpen    ErrorType = "CIRCUIT_OPEN"
 )
 
 // AppError represents a custom application error with context.
@@ -83,6 +84,24 @@ func NewRetryExhaustedError(err error, attempts int) *AppError {
 	}
 }
 
+// NewCircuitOpenError creates an error for a request rejected because the
+// circuit breaker of its host is open.
+func NewCircuitOpenError(host string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeCircuitOpen,
+		Message: fmt.Sprintf("circuit breaker open for %s", host),
+	}
+}
+
+// IsCircuitOpenError checks if an error is a circuit breaker rejection.
+func IsCircuitOpenError(err error) bool {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Type == ErrorTypeCircuitOpen
+	}
+	return false
+}
+
 // IsNetworkError checks if an error is a network error.
 func IsNetworkError(err error) bool {
 	var appErr *AppError
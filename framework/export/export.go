@@ -0,0 +1,321 @@
+// Package export writes the site as a tree of static files, so it can be
+// served by any static host when the server or its content API is down.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"statigo/framework/cache"
+	"statigo/framework/middleware"
+	"statigo/framework/router"
+)
+
+// notFoundPath is requested to render the 404 page.
+const notFoundPath = "/statigo-export-not-found"
+
+// Config configures a static export.
+type Config struct {
+	OutDir       string
+	Router       http.Handler
+	Routes       *router.Registry
+	PathExpander func(ctx context.Context, canonical string) ([]string, error) // Expands "{param}" routes into concrete paths
+	StaticFS     fs.FS                                                         // Files served from the site root
+	Files        []string                                                      // Non-page paths written as files, e.g. "/sitemap.xml"
+	Redirects    *middleware.RedirectRegistry
+	Logger       *slog.Logger
+}
+
+// Result summarizes an export.
+type Result struct {
+	Pages     int
+	Files     int
+	Assets    int
+	Redirects int
+	Failed    int
+}
+
+// Run renders every page, file and static asset through the router and
+// writes them below config.OutDir. Pages are written as <path>/index.html;
+// a 404.html, a _redirects file and, for files without an extension, a
+// _headers file with their content types are generated for static hosts.
+// Paths that fail to render are logged and counted in Result.Failed.
+func Run(ctx context.Context, config Config) (Result, error) {
+	var result Result
+	if err := os.MkdirAll(config.OutDir, 0755); err != nil {
+		return result, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	paths, err := pagePaths(ctx, config)
+	if err != nil {
+		return result, err
+	}
+
+	for _, pagePath := range paths {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		page, ok := render(config, pagePath, http.StatusOK)
+		if !ok {
+			result.Failed++
+			continue
+		}
+		if err := writeFile(config.OutDir, pageFile(pagePath), page.body.Bytes()); err != nil {
+			return result, err
+		}
+		result.Pages++
+		config.Logger.Debug("Exported page", slog.String("path", pagePath))
+	}
+
+	var headers []string
+	for _, filePath := range config.Files {
+		file, ok := render(config, filePath, http.StatusOK)
+		if !ok {
+			result.Failed++
+			continue
+		}
+		if err := writeFile(config.OutDir, filePath, file.body.Bytes()); err != nil {
+			return result, err
+		}
+		// Static hosts guess content types from extensions
+		if path.Ext(filePath) == "" {
+			headers = append(headers, filePath, "  Content-Type: "+file.header.Get("Content-Type"))
+		}
+		result.Files++
+	}
+
+	if config.StaticFS != nil {
+		assets, err := exportAssets(config)
+		result.Assets = assets
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if page, ok := render(config, notFoundPath, http.StatusNotFound); ok {
+		if err := writeFile(config.OutDir, "404.html", page.body.Bytes()); err != nil {
+			return result, err
+		}
+	}
+
+	if config.Redirects != nil {
+		lines := redirectLines(config.Redirects.Rules(), config.Logger)
+		result.Redirects = len(lines)
+		if err := writeLines(config.OutDir, "_redirects", lines); err != nil {
+			return result, err
+		}
+	}
+
+	if len(headers) > 0 {
+		if err := writeLines(config.OutDir, "_headers", headers); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// pagePaths returns the concrete paths of all routes with a handler.
+func pagePaths(ctx context.Context, config Config) ([]string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, route := range config.Routes.GetAll() {
+		if route.Handler == nil {
+			continue
+		}
+
+		if !strings.Contains(route.Path, "{") {
+			add(route.Path)
+			continue
+		}
+
+		if config.PathExpander == nil {
+			config.Logger.Warn("Skipping parameterized route without path expander",
+				slog.String("path", route.Path),
+			)
+			continue
+		}
+
+		expanded, err := config.PathExpander(ctx, route.Canonical)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand route %s: %w", route.Canonical, err)
+		}
+		for _, p := range expanded {
+			add(p)
+		}
+	}
+	return paths, nil
+}
+
+// exportAssets writes every file of the static filesystem, rendered through
+// the router so assets are minified as they are when served.
+func exportAssets(config Config) (int, error) {
+	count := 0
+	err := fs.WalkDir(config.StaticFS, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, readErr := fs.ReadFile(config.StaticFS, name)
+		if readErr != nil {
+			return fmt.Errorf("failed to read static file %s: %w", name, readErr)
+		}
+		if asset, ok := render(config, "/"+name, http.StatusOK); ok {
+			data = asset.body.Bytes()
+		}
+
+		if err := writeFile(config.OutDir, name, data); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// render requests a path from the router and reports whether it answered
+// with the expected status.
+func render(config Config, requestPath string, expected int) (*responseRecorder, bool) {
+	// Rendered as an internal re-render, so the current content is exported
+	req, err := http.NewRequestWithContext(cache.WithRevalidation(context.Background()), http.MethodGet, requestPath, nil)
+	if err != nil {
+		config.Logger.Warn("Failed to export path", slog.String("path", requestPath), slog.String("error", err.Error()))
+		return nil, false
+	}
+
+	rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+	config.Router.ServeHTTP(rec, req)
+
+	if rec.status != expected {
+		config.Logger.Warn("Failed to export path",
+			slog.String("path", requestPath),
+			slog.Int("status", rec.status),
+		)
+		return rec, false
+	}
+	return rec, true
+}
+
+// pageFile returns the file a page path is written to.
+func pageFile(pagePath string) string {
+	pagePath = strings.Trim(pagePath, "/")
+	if pagePath == "" {
+		return "index.html"
+	}
+	if ext := path.Ext(pagePath); ext != "" && mime.TypeByExtension(ext) != "" {
+		return pagePath
+	}
+	return pagePath + "/index.html"
+}
+
+// writeFile writes data to name below dir, creating parent directories.
+func writeFile(dir, name string, data []byte) error {
+	target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeLines writes lines to name below dir.
+func writeLines(dir, name string, lines []string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by statigo export\n")
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return writeFile(dir, name, buf.Bytes())
+}
+
+// redirectLines converts redirect rules to the _redirects format used by
+// Netlify and Cloudflare Pages. Regex rules have no equivalent and are skipped.
+func redirectLines(rules []middleware.RedirectRule, logger *slog.Logger) []string {
+	var lines []string
+	for _, rule := range rules {
+		if rule.Regex || strings.Contains(rule.Host, "{") {
+			logger.Warn("Skipping redirect that static hosts cannot express",
+				slog.String("source", rule.From),
+			)
+			continue
+		}
+
+		from := placeholders(strings.TrimSuffix(rule.From, "*"))
+		if strings.HasSuffix(rule.From, "*") {
+			from += "*"
+		}
+		if rule.Host != "" {
+			from = "https://" + rule.Host + from
+		}
+
+		status := rule.Status
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		to := strings.ReplaceAll(placeholders(rule.To), "*", ":splat")
+		if status == http.StatusGone {
+			to = "/404.html"
+		}
+
+		lines = append(lines, from+" "+to+" "+strconv.Itoa(status))
+	}
+	return lines
+}
+
+// placeholders rewrites "{name}" placeholders as ":name".
+func placeholders(pattern string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(pattern, "{")
+		end := strings.Index(pattern, "}")
+		if start < 0 || end < start {
+			b.WriteString(pattern)
+			return b.String()
+		}
+		b.WriteString(pattern[:start])
+		b.WriteString(":" + pattern[start+1:end])
+		pattern = pattern[end+1:]
+	}
+}
+
+// responseRecorder captures a response rendered in-process.
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (w *responseRecorder) Header() http.Header { return w.header }
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"statigo/framework/middleware"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "/blog/post", want: "/blog/post"},
+		{pattern: "/blog/{slug}", want: "/blog/:slug"},
+		{pattern: "/{lang}/blog/{slug}", want: "/:lang/blog/:slug"},
+		{pattern: "/{year}-{month}/", want: "/:year-:month/"},
+		{pattern: "/blog/{slug", want: "/blog/{slug"},
+		{pattern: "/blog/}{slug}", want: "/blog/}{slug}"},
+		{pattern: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := placeholders(tt.pattern); got != tt.want {
+				t.Errorf("placeholders(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectLines(t *testing.T) {
+	tests := []struct {
+		name string
+		rule middleware.RedirectRule
+		want []string
+	}{
+		{
+			name: "static redirect defaults to 301",
+			rule: middleware.RedirectRule{From: "/old", To: "/new"},
+			want: []string{"/old /new 301"},
+		},
+		{
+			name: "explicit status",
+			rule: middleware.RedirectRule{From: "/old", To: "/new", Status: 302},
+			want: []string{"/old /new 302"},
+		},
+		{
+			name: "placeholders",
+			rule: middleware.RedirectRule{From: "/posts/{slug}", To: "/blog/{slug}"},
+			want: []string{"/posts/:slug /blog/:slug 301"},
+		},
+		{
+			name: "splat",
+			rule: middleware.RedirectRule{From: "/docs/*", To: "/guide/*"},
+			want: []string{"/docs/* /guide/:splat 301"},
+		},
+		{
+			name: "placeholder and splat",
+			rule: middleware.RedirectRule{From: "/{lang}/docs/*", To: "/docs/{lang}/*", Status: 308},
+			want: []string{"/:lang/docs/* /docs/:lang/:splat 308"},
+		},
+		{
+			name: "gone",
+			rule: middleware.RedirectRule{From: "/removed", To: "/", Status: 410},
+			want: []string{"/removed /404.html 410"},
+		},
+		{
+			name: "static host",
+			rule: middleware.RedirectRule{From: "/*", To: "https://example.com/*", Host: "old.example.com"},
+			want: []string{"https://old.example.com/* https://example.com/:splat 301"},
+		},
+		{
+			name: "regex skipped",
+			rule: middleware.RedirectRule{From: `^/p/(?P<id>\d+)$`, To: "/posts/{id}", Regex: true},
+			want: nil,
+		},
+		{
+			name: "host placeholder skipped",
+			rule: middleware.RedirectRule{From: "/*", To: "https://example.com/{sub}/*", Host: "{sub}.example.org"},
+			want: nil,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redirectLines([]middleware.RedirectRule{tt.rule}, logger)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("redirectLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,9 +8,10 @@ import (
 
 // CheckResult represents the result of a health check.
 type CheckResult struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
 }
 
 // HealthStatus represents overall health status.
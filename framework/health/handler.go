@@ -19,6 +19,11 @@ func NewHandler(checkTimeout time.Duration) *Handler {
 	}
 }
 
+// AddCheck registers a health check reported by Readiness.
+func (h *Handler) AddCheck(check CheckFunc) {
+	h.checker.AddCheck(check)
+}
+
 // Liveness is a simple liveness probe that returns OK if the app is running.
 // Use for Kubernetes liveness probes or simple uptime checks.
 func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
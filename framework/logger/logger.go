@@ -18,8 +18,13 @@ type contextKey string
 
 const requestIDKey contextKey = "request_id"
 
-// InitLogger initializes and returns a structured logger.
+// InitLogger initializes and returns a structured logger writing to stdout.
 func InitLogger(level string) *slog.Logger {
+	return NewLogger(os.Stdout, level)
+}
+
+// NewLogger returns a structured logger writing to w.
+func NewLogger(w io.Writer, level string) *slog.Logger {
 	var logLevel slog.Level
 	switch strings.ToUpper(level) {
 	case "DEBUG":
@@ -42,9 +47,9 @@ func InitLogger(level string) *slog.Logger {
 	}
 
 	if strings.ToUpper(format) == "JSON" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(w, opts)
 	} else {
-		handler = NewBracketHandler(os.Stdout, opts)
+		handler = NewBracketHandler(w, opts)
 	}
 
 	return slog.New(handler)
@@ -2,18 +2,50 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"statigo/framework/cache"
 	fwctx "statigo/framework/context"
+	"statigo/framework/security"
 )
 
-// CacheMiddleware creates middleware that serves cached responses.
-// Supports ETag-based cache validation, returning 304 Not Modified
-// when the client's cached version matches.
+// DefaultIncrementalInterval is the revalidation interval used for
+// incremental routes that don't declare one.
+const DefaultIncrementalInterval = 24 * time.Hour
+
+// CacheConfig configures the cache middleware.
+type CacheConfig struct {
+	// MaxStaleAge bounds how old a stale entry may be and still be served
+	// while it is re-rendered in the background. Older entries block the
+	// request until a fresh copy is rendered. Zero disables the bound.
+	MaxStaleAge time.Duration
+}
+
+// DefaultCacheConfig returns default configuration.
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxStaleAge: 7 * 24 * time.Hour,
+	}
+}
+
+// CacheMiddleware creates middleware that serves cached responses with the
+// default configuration.
 func CacheMiddleware(cacheManager *cache.Manager, logger *slog.Logger) func(http.Handler) http.Handler {
+	return CacheMiddlewareWithConfig(cacheManager, DefaultCacheConfig(), logger)
+}
+
+// CacheMiddlewareWithConfig creates middleware that serves cached responses.
+// Supports ETag-based cache validation, returning 304 Not Modified
+// when the client's cached version matches. Stale entries are served
+// immediately (X-Cache: STALE) while a single background re-render per
+// cache key replaces them. When rendering fails with a 5xx, the last cached
+// copy is served instead of the error. Cache tags declared by handlers are emitted as
+// Surrogate-Key and Cache-Tag headers so a CDN can purge the same pages.
+func CacheMiddlewareWithConfig(cacheManager *cache.Manager, config CacheConfig, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Only cache GET requests
@@ -34,44 +66,38 @@ func CacheMiddleware(cacheManager *cache.Manager, logger *slog.Logger) func(http
 			// Generate cache key
 			cacheKey := cache.GetCacheKey(canonical)
 
-			// Try to get from cache
-			entry, found := cacheManager.Get(cacheKey)
-			if found && !entry.IsStale() {
-				etag := `W/"` + entry.ETag + `"`
-
-				// Check If-None-Match for 304 Not Modified
-				if etagMatch(r.Header.Get("If-None-Match"), etag) {
-					w.Header().Set("ETag", etag)
-					w.Header().Set("Cache-Control", "no-cache")
-					w.Header().Set("X-Cache", "HIT")
-					w.WriteHeader(http.StatusNotModified)
-					return
-				}
+			// Collect the tags the handler declares while rendering, and
+			// whether it had to fall back to degraded content
+			ctx := fwctx.WithDegraded(fwctx.WithCacheTags(r.Context()))
+			fwctx.AddCacheTags(ctx, cache.PathTag(canonical))
+			r = r.WithContext(ctx)
 
-				// Serve from cache
-				content, err := cache.GetDecompressedContent(entry)
-				if err != nil {
-					logger.Warn("Failed to decompress cached content",
-						slog.String("key", cacheKey),
-						slog.String("error", err.Error()),
-					)
-					next.ServeHTTP(w, r)
-					return
+			// Try to get from cache (internal re-renders always bypass it)
+			entry, found := cacheManager.Get(cacheKey)
+			if found && !cache.IsRevalidation(r.Context()) {
+				if !entry.IsStale() {
+					if serveCached(w, r, entry, "HIT", logger) {
+						return
+					}
+				} else if config.MaxStaleAge <= 0 || time.Since(entry.CachedAt) < config.MaxStaleAge {
+					// Serve stale copy now, refresh in the background
+					if cacheManager.Revalidate(cacheKey, entry.Path) {
+						logger.Debug("Revalidating stale cache entry",
+							slog.String("key", cacheKey),
+							slog.String("path", entry.Path),
+						)
+					}
+					if serveCached(w, r, entry, "STALE", logger) {
+						return
+					}
 				}
-
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Header().Set("X-Cache", "HIT")
-				w.Header().Set("ETag", etag)
-				w.Header().Set("Cache-Control", "no-cache")
-				w.Write(content)
-				return
 			}
 
 			// Cache miss or stale - capture response for caching
 			strategy := fwctx.GetStrategy(r.Context())
 			if strategy == "" || strategy == "dynamic" {
-				// Don't cache dynamic content
-				next.ServeHTTP(w, r)
+				// Don't cache dynamic content, but still expose its tags
+				next.ServeHTTP(&tagHeaderWriter{ResponseWriter: w, ctx: ctx}, r)
 				return
 			}
 
@@ -84,13 +110,38 @@ func CacheMiddleware(cacheManager *cache.Manager, logger *slog.Logger) func(http
 
 			// Serve the request (response is buffered in the recorder)
 			next.ServeHTTP(rec, r)
+			tags := fwctx.GetCacheTags(ctx)
+
+			// Keep serving the last good copy while the page fails to render
+			if found && rec.statusCode >= http.StatusInternalServerError && !cache.IsRevalidation(r.Context()) {
+				logger.Warn("Serving cached response after render failure",
+					slog.String("key", cacheKey),
+					slog.Int("status", rec.statusCode),
+				)
+				w.Header().Del("Retry-After")
+				if serveCached(w, r, entry, "STALE", logger) {
+					return
+				}
+			}
+
+			// Drop entries for pages that no longer exist
+			if found && (rec.statusCode == http.StatusNotFound || rec.statusCode == http.StatusGone) {
+				if err := cacheManager.Delete(cacheKey); err != nil {
+					logger.Warn("Failed to remove cached response",
+						slog.String("key", cacheKey),
+						slog.String("error", err.Error()),
+					)
+				}
+			}
 
-			// Only cache successful responses
-			if rec.statusCode == http.StatusOK {
+			// Only cache successful responses rendered from live content
+			if rec.statusCode == http.StatusOK && fwctx.GetDegraded(ctx) == "" {
 				content := rec.body.Bytes()
 
-				// Store in cache
-				if err := cacheManager.Set(cacheKey, content, strategy, r.URL.Path); err != nil {
+				// Store in cache, expiring incremental entries after their interval
+				ttl := cacheTTL(strategy, fwctx.GetInterval(r.Context()))
+				placeholder := w.Header().Get(security.CSPPlaceholderHeader)
+				if err := cacheManager.Set(cacheKey, content, strategy, r.URL.Path, placeholder, ttl, tags...); err != nil {
 					logger.Warn("Failed to cache response",
 						slog.String("key", cacheKey),
 						slog.String("error", err.Error()),
@@ -99,6 +150,8 @@ func CacheMiddleware(cacheManager *cache.Manager, logger *slog.Logger) func(http
 					logger.Debug("Cached response",
 						slog.String("key", cacheKey),
 						slog.String("strategy", strategy),
+						slog.Duration("ttl", ttl),
+						slog.Int("tags", len(tags)),
 					)
 
 					// Set ETag from the newly cached entry
@@ -110,12 +163,101 @@ func CacheMiddleware(cacheManager *cache.Manager, logger *slog.Logger) func(http
 			}
 
 			// Write the buffered response to the underlying writer
+			setTagHeaders(w.Header(), tags)
 			w.WriteHeader(rec.statusCode)
 			w.Write(rec.body.Bytes())
 		})
 	}
 }
 
+// serveCached writes a cached entry to the response, answering conditional
+// requests with 304 Not Modified. It returns false if the entry could not be
+// served and the request should be rendered instead.
+func serveCached(w http.ResponseWriter, r *http.Request, entry *cache.Entry, status string, logger *slog.Logger) bool {
+	etag := `W/"` + entry.ETag + `"`
+	setTagHeaders(w.Header(), entry.Tags)
+
+	// Check If-None-Match for 304 Not Modified
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Cache", status)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	// Serve from cache
+	content, err := cache.GetDecompressedContent(entry)
+	if err != nil {
+		logger.Warn("Failed to decompress cached content",
+			slog.String("key", entry.Key),
+			slog.String("error", err.Error()),
+		)
+		return false
+	}
+
+	// Let the CSP middleware swap the entry's placeholder for a fresh nonce
+	if entry.NoncePlaceholder != "" {
+		w.Header().Set(security.CSPPlaceholderHeader, entry.NoncePlaceholder)
+	} else {
+		w.Header().Del(security.CSPPlaceholderHeader)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Cache", status)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(content)
+	return true
+}
+
+// setTagHeaders exposes cache tags to a CDN in front of the server.
+// Surrogate-Key is space-separated (Fastly), Cache-Tag comma-separated (Cloudflare).
+func setTagHeaders(h http.Header, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	h.Set("Surrogate-Key", strings.Join(tags, " "))
+	h.Set("Cache-Tag", strings.Join(tags, ","))
+}
+
+// tagHeaderWriter sets cache tag headers right before the response header is
+// written, once the handler has had a chance to declare its tags.
+type tagHeaderWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+}
+
+// WriteHeader sets the tag headers and writes the status code.
+func (w *tagHeaderWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		setTagHeaders(w.ResponseWriter.Header(), fwctx.GetCacheTags(w.ctx))
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write sets the tag headers if needed and writes the body.
+func (w *tagHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// cacheTTL returns how long an entry stored with the given strategy stays fresh.
+// Only incremental entries expire on their own; zero means no expiry.
+func cacheTTL(strategy string, interval time.Duration) time.Duration {
+	if strategy != "incremental" {
+		return 0
+	}
+	if interval <= 0 {
+		return DefaultIncrementalInterval
+	}
+	return interval
+}
+
 // etagMatch checks if the If-None-Match header value matches the given ETag.
 func etagMatch(ifNoneMatch, etag string) bool {
 	if ifNoneMatch == "" {
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	fwctx "statigo/framework/context"
+	"statigo/framework/security"
+)
+
+// ClientIP creates a middleware that resolves the real client IP and scheme
+// once and stores them in the request context, so every later middleware and
+// handler sees the same values through security.GetClientIP and
+// security.IsHTTPS.
+func ClientIP(resolver *security.ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := fwctx.SetClientIP(r.Context(), resolver.Resolve(r))
+			ctx = fwctx.SetScheme(ctx, resolver.Scheme(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"statigo/framework/security"
+)
+
+// CSP creates a middleware that sends the Content-Security-Policy header.
+// With config.Nonce set, every request gets a fresh nonce, and the
+// placeholder named by the security.CSPPlaceholderHeader response header is
+// replaced with it in HTML responses.
+func CSP(config security.CSPConfig) func(http.Handler) http.Handler {
+	header := config.HeaderName()
+	static := config.Build("")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.ReportURI != "" {
+				w.Header().Set("Reporting-Endpoints", security.CSPReportGroup+`="`+config.ReportURI+`"`)
+			}
+
+			if !config.Nonce {
+				w.Header().Set(header, static)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			nonce := security.NewCSPNonce()
+			w.Header().Set(header, config.Build(nonce))
+
+			nw := &nonceWriter{ResponseWriter: w, header: header, nonce: []byte(nonce)}
+			next.ServeHTTP(nw, r)
+			nw.finish()
+		})
+	}
+}
+
+// nonceWriter replaces the nonce placeholder in HTML responses. The tail of
+// each write that could start a placeholder is held back until the next
+// write, so placeholders split across writes are replaced too.
+type nonceWriter struct {
+	http.ResponseWriter
+	header      string
+	nonce       []byte
+	placeholder []byte // Placeholder rendered into this response
+	wroteHeader bool
+	rewrite     bool   // Response is HTML with a placeholder
+	pending     []byte // Held-back tail of the previous write
+}
+
+func (nw *nonceWriter) WriteHeader(code int) {
+	if nw.wroteHeader {
+		return
+	}
+	nw.wroteHeader = true
+
+	// A 304 reuses the body the browser already has, which carries the
+	// nonce of the earlier response, so keep the policy it came with
+	if code == http.StatusNotModified {
+		nw.Header().Del(nw.header)
+	}
+	nw.placeholder = []byte(nw.Header().Get(security.CSPPlaceholderHeader))
+	nw.Header().Del(security.CSPPlaceholderHeader)
+	nw.rewrite = len(nw.placeholder) > 0 && strings.HasPrefix(nw.Header().Get("Content-Type"), "text/html")
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *nonceWriter) Write(b []byte) (int, error) {
+	if !nw.wroteHeader {
+		if nw.Header().Get("Content-Type") == "" {
+			nw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		nw.WriteHeader(http.StatusOK)
+	}
+	if !nw.rewrite {
+		return nw.ResponseWriter.Write(b)
+	}
+
+	data := append(nw.pending, b...)
+	data = bytes.ReplaceAll(data, nw.placeholder, nw.nonce)
+
+	// Hold back a suffix that may be the start of a placeholder
+	keep := 0
+	for n := min(len(nw.placeholder)-1, len(data)); n > 0; n-- {
+		if bytes.HasSuffix(data, nw.placeholder[:n]) {
+			keep = n
+			break
+		}
+	}
+	nw.pending = append(nw.pending[:0:0], data[len(data)-keep:]...)
+
+	if _, err := nw.ResponseWriter.Write(data[:len(data)-keep]); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can flush it.
+func (nw *nonceWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
+
+// finish writes any held-back bytes.
+func (nw *nonceWriter) finish() {
+	if len(nw.pending) > 0 {
+		nw.ResponseWriter.Write(nw.pending)
+		nw.pending = nil
+	}
+}
+
+// maxCSPReportBytes limits the size of a violation report.
+const maxCSPReportBytes = 64 * 1024
+
+// CSPReportHandler collects CSP violation reports, accepting both the
+// legacy report-uri format and Reporting API batches, and logs them.
+func CSPReportHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportBytes))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for _, report := range parseCSPReports(body) {
+			logger.Warn("CSP violation",
+				"ip", security.GetClientIP(r),
+				"document", report.DocumentURI,
+				"directive", report.Directive,
+				"blocked", report.BlockedURI,
+				"source", report.SourceFile,
+				"line", report.LineNumber,
+				"disposition", report.Disposition,
+				"sample", report.Sample,
+			)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// cspReport holds the fields of a violation report worth logging.
+type cspReport struct {
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	SourceFile  string
+	LineNumber  int
+	Disposition string
+	Sample      string
+}
+
+// parseCSPReports decodes a report-uri body ({"csp-report": {...}}) or a
+// Reporting API batch ([{"type": "csp-violation", "body": {...}}]).
+func parseCSPReports(body []byte) []cspReport {
+	var legacy struct {
+		Report *struct {
+			DocumentURI        string `json:"document-uri"`
+			EffectiveDirective string `json:"effective-directive"`
+			ViolatedDirective  string `json:"violated-directive"`
+			BlockedURI         string `json:"blocked-uri"`
+			SourceFile         string `json:"source-file"`
+			LineNumber         int    `json:"line-number"`
+			Disposition        string `json:"disposition"`
+			ScriptSample       string `json:"script-sample"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		rep := legacy.Report
+		directive := rep.EffectiveDirective
+		if directive == "" {
+			directive = rep.ViolatedDirective
+		}
+		return []cspReport{{
+			DocumentURI: rep.DocumentURI,
+			Directive:   directive,
+			BlockedURI:  rep.BlockedURI,
+			SourceFile:  rep.SourceFile,
+			LineNumber:  rep.LineNumber,
+			Disposition: rep.Disposition,
+			Sample:      rep.ScriptSample,
+		}}
+	}
+
+	var batch []struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			SourceFile         string `json:"sourceFile"`
+			LineNumber         int    `json:"lineNumber"`
+			Disposition        string `json:"disposition"`
+			Sample             string `json:"sample"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil
+	}
+
+	var reports []cspReport
+	for _, item := range batch {
+		if item.Type != "csp-violation" {
+			continue
+		}
+		reports = append(reports, cspReport{
+			DocumentURI: item.Body.DocumentURL,
+			Directive:   item.Body.EffectiveDirective,
+			BlockedURI:  item.Body.BlockedURL,
+			SourceFile:  item.Body.SourceFile,
+			LineNumber:  item.Body.LineNumber,
+			Disposition: item.Body.Disposition,
+			Sample:      item.Body.Sample,
+		})
+	}
+	return reports
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"statigo/framework/security"
+)
+
+func TestNonceWriterReplacesSplitPlaceholders(t *testing.T) {
+	const placeholder = "PLACEHOLDER-1234"
+	const nonce = "n0nce"
+
+	tests := []struct {
+		name        string
+		contentType string
+		writes      []string
+		want        string
+	}{
+		{
+			name:   "single write",
+			writes: []string{`<script nonce="PLACEHOLDER-1234">`},
+			want:   `<script nonce="n0nce">`,
+		},
+		{
+			name:   "split in the middle",
+			writes: []string{`<script nonce="PLACE`, `HOLDER-1234">`},
+			want:   `<script nonce="n0nce">`,
+		},
+		{
+			name:   "split after first byte",
+			writes: []string{`<script nonce="P`, `LACEHOLDER-1234">`},
+			want:   `<script nonce="n0nce">`,
+		},
+		{
+			name:   "split before last byte",
+			writes: []string{`<script nonce="PLACEHOLDER-123`, `4">`},
+			want:   `<script nonce="n0nce">`,
+		},
+		{
+			name:   "one byte per write",
+			writes: strings.Split(`<a nonce="PLACEHOLDER-1234"><b nonce="PLACEHOLDER-1234">`, ""),
+			want:   `<a nonce="n0nce"><b nonce="n0nce">`,
+		},
+		{
+			name:   "prefix that never completes",
+			writes: []string{`<p>PLACE`, `</p> PLACEHOLDER-12`},
+			want:   `<p>PLACE</p> PLACEHOLDER-12`,
+		},
+		{
+			name:   "placeholder is the whole write",
+			writes: []string{`<i nonce="`, `PLACEHOLDER-1234`, `">`},
+			want:   `<i nonce="n0nce">`,
+		},
+		{
+			name:        "non-HTML response left alone",
+			contentType: "application/json",
+			writes:      []string{`{"nonce":"PLACE`, `HOLDER-1234"}`},
+			want:        `{"nonce":"PLACEHOLDER-1234"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "text/html; charset=utf-8"
+			}
+
+			rec := httptest.NewRecorder()
+			nw := &nonceWriter{ResponseWriter: rec, header: "Content-Security-Policy", nonce: []byte(nonce)}
+			nw.Header().Set("Content-Type", contentType)
+			nw.Header().Set(security.CSPPlaceholderHeader, placeholder)
+			nw.WriteHeader(http.StatusOK)
+			for _, chunk := range tt.writes {
+				n, err := nw.Write([]byte(chunk))
+				if err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				if n != len(chunk) {
+					t.Fatalf("Write returned %d, want %d", n, len(chunk))
+				}
+			}
+			nw.finish()
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if got := rec.Header().Get(security.CSPPlaceholderHeader); got != "" {
+				t.Errorf("placeholder header leaked: %q", got)
+			}
+		})
+	}
+}
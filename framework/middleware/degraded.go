@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	fwctx "statigo/framework/context"
+)
+
+// DegradedHeader marks responses rendered from fallback content. Its value
+// is the reason given to fwctx.MarkDegraded, e.g. "snapshot".
+const DegradedHeader = "X-Content-Degraded"
+
+// Degraded creates a middleware that sets DegradedHeader on responses whose
+// handlers marked them degraded, and keeps shared caches from storing them.
+func Degraded() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(fwctx.WithDegraded(r.Context()))
+			next.ServeHTTP(&degradedWriter{ResponseWriter: w, r: r}, r)
+		})
+	}
+}
+
+// degradedWriter sets the degraded headers right before the response header
+// is written, once the handler has fetched its content.
+type degradedWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+}
+
+func (w *degradedWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if reason := fwctx.GetDegraded(w.r.Context()); reason != "" {
+			w.Header().Set(DegradedHeader, reason)
+			w.Header().Set("Cache-Control", "no-store")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *degradedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can flush it.
+func (w *degradedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,72 +1,329 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"statigo/framework/security"
 )
 
-// HoneypotMiddleware creates a middleware that intercepts honeypot paths and bans IPs.
-func HoneypotMiddleware(banList *security.IPBanList, honeypotPaths []string, logger *slog.Logger) func(http.Handler) http.Handler {
-	// Create a map for faster lookup
-	pathMap := make(map[string]bool)
-	for _, path := range honeypotPaths {
-		pathMap[path] = true
+// Honeypot rule actions.
+const (
+	HoneypotBan   = "ban"   // Ban the client immediately
+	HoneypotScore = "score" // Add points to the client's threat score
+	HoneypotLog   = "log"   // Only log the hit
+)
+
+// Honeypot decoy responses.
+const (
+	DecoyNotFound = "notFound" // Plain 404 (default)
+	DecoyLogin    = "login"    // Fake login page that rejects every attempt
+	DecoyDrip     = "drip"     // Response trickled out one byte per second
+)
+
+const (
+	dripInterval  = time.Second
+	dripDuration  = 10 * time.Second // Kept below the server's write timeout
+	maxDripClient = 32               // Concurrent drips before falling back to 404
+)
+
+// HoneypotConfig represents the honeypot configuration file.
+type HoneypotConfig struct {
+	Rules []HoneypotRule `json:"rules"`
+}
+
+// HoneypotRule describes paths only scanners request and how to answer them.
+type HoneypotRule struct {
+	Path     string  `json:"path,omitempty"`     // Glob; "*" and "?" stay within a segment, "**" spans segments
+	Prefix   string  `json:"prefix,omitempty"`   // Matches any path starting with this prefix
+	Action   string  `json:"action"`             // "ban", "score" or "log"
+	Duration string  `json:"duration,omitempty"` // Ban duration for "ban"; empty bans permanently
+	Points   float64 `json:"points,omitempty"`   // Score for "score"; defaults to the honeypot weight
+	Decoy    string  `json:"decoy,omitempty"`    // "notFound" (default), "login" or "drip"
+}
+
+// honeypotRule is a validated HoneypotRule ready for matching.
+type honeypotRule struct {
+	HoneypotRule
+	pattern  *regexp.Regexp // Nil for prefix rules
+	prefix   string         // Lowercased prefix
+	duration time.Duration
+}
+
+// HoneypotRegistry holds honeypot rules in configuration order.
+type HoneypotRegistry struct {
+	rules []*honeypotRule
+}
+
+// LoadHoneypotsFromJSON loads honeypot rules from a JSON file.
+func LoadHoneypotsFromJSON(configFS fs.FS, filePath string, logger *slog.Logger) (*HoneypotRegistry, error) {
+	data, err := fs.ReadFile(configFS, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read honeypots file: %w", err)
+	}
+
+	var config HoneypotConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse honeypots JSON: %w", err)
 	}
 
+	registry := &HoneypotRegistry{}
+	for _, rule := range config.Rules {
+		compiled, err := compileHoneypotRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		registry.rules = append(registry.rules, compiled)
+	}
+
+	logger.Info("Loaded honeypot rules", "file", filePath, "rules", len(registry.rules))
+	return registry, nil
+}
+
+// compileHoneypotRule validates a rule and prepares it for matching.
+func compileHoneypotRule(rule HoneypotRule) (*honeypotRule, error) {
+	compiled := &honeypotRule{HoneypotRule: rule}
+	name := rule.Path + rule.Prefix
+
+	switch {
+	case rule.Path != "" && rule.Prefix != "":
+		return nil, fmt.Errorf("honeypot rule %s: set either path or prefix, not both", name)
+	case rule.Path != "":
+		pattern, err := globToRegex(trimTrailingSlash(rule.Path))
+		if err != nil {
+			return nil, fmt.Errorf("invalid honeypot pattern %s: %w", rule.Path, err)
+		}
+		compiled.pattern = pattern
+	case rule.Prefix != "":
+		compiled.prefix = strings.ToLower(rule.Prefix)
+	default:
+		return nil, fmt.Errorf("honeypot rule without path or prefix")
+	}
+
+	switch rule.Action {
+	case HoneypotBan:
+		if rule.Duration != "" {
+			duration, err := time.ParseDuration(rule.Duration)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("invalid ban duration %q for honeypot %s", rule.Duration, name)
+			}
+			compiled.duration = duration
+		}
+	case HoneypotScore, HoneypotLog:
+	default:
+		return nil, fmt.Errorf("unknown action %q for honeypot %s", rule.Action, name)
+	}
+
+	switch rule.Decoy {
+	case "":
+		compiled.Decoy = DecoyNotFound
+	case DecoyNotFound, DecoyLogin, DecoyDrip:
+	default:
+		return nil, fmt.Errorf("unknown decoy %q for honeypot %s", rule.Decoy, name)
+	}
+
+	return compiled, nil
+}
+
+// globToRegex converts a path glob to an anchored, case-insensitive regex.
+func globToRegex(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "/**/"):
+			// Zero or more directories
+			b.WriteString("/(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// trimTrailingSlash removes a trailing slash from any path but the root.
+func trimTrailingSlash(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// Match returns the first rule matching the path, or nil.
+func (hr *HoneypotRegistry) Match(path string) *HoneypotRule {
+	if rule := hr.match(path); rule != nil {
+		return &rule.HoneypotRule
+	}
+	return nil
+}
+
+// match returns the first compiled rule matching the path, or nil.
+// Matching ignores case and a trailing slash.
+func (hr *HoneypotRegistry) match(path string) *honeypotRule {
+	path = trimTrailingSlash(path)
+	lower := strings.ToLower(path)
+
+	for _, rule := range hr.rules {
+		if rule.pattern != nil && rule.pattern.MatchString(path) {
+			return rule
+		}
+		if rule.prefix != "" && strings.HasPrefix(lower, rule.prefix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// HoneypotMiddleware creates a middleware that intercepts honeypot paths,
+// applies the matching rule's action and answers with its decoy.
+func HoneypotMiddleware(registry *HoneypotRegistry, scorer *security.ThreatScorer, banList *security.IPBanList, logger *slog.Logger) func(http.Handler) http.Handler {
+	// Bounds the connections held open by drip decoys
+	drips := make(chan struct{}, maxDripClient)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check if the current path is a honeypot
-			if pathMap[r.URL.Path] {
-				clientIP := GetClientIP(r)
-				userAgent := r.UserAgent()
-				path := r.URL.Path
-
-				// Ban the IP
-				if err := banList.BanIP(clientIP, "Honeypot trigger", userAgent, path); err != nil {
-					logger.Error("Failed to ban IP",
-						"ip", clientIP,
-						"error", err,
-					)
+			rule := registry.match(r.URL.Path)
+			if rule == nil {
+				// Not a honeypot path, continue to next middleware
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			clientIP := security.GetClientIP(r)
+			userAgent := r.UserAgent()
+			path := r.URL.Path
+
+			switch rule.Action {
+			case HoneypotBan:
+				entry := security.BanEntry{
+					IP:        clientIP,
+					Reason:    "Honeypot trigger",
+					BannedAt:  time.Now(),
+					UserAgent: userAgent,
+					Path:      path,
 				}
+				if rule.duration > 0 {
+					entry.ExpiresAt = entry.BannedAt.Add(rule.duration)
+				}
+				if err := banList.Ban(entry); errors.Is(err, security.ErrAllowlisted) {
+					logger.Info("Not banning allowlisted IP", "ip", clientIP, "path", path)
+				} else if err != nil {
+					logger.Error("Failed to ban IP", "ip", clientIP, "error", err)
+				}
+			case HoneypotScore:
+				scorer.Record(security.ThreatEvent{
+					IP:        clientIP,
+					Signal:    security.SignalHoneypot,
+					Path:      path,
+					UserAgent: userAgent,
+					Points:    rule.Points,
+				})
+			}
 
-				logger.Warn("Honeypot triggered",
-					"ip", clientIP,
-					"path", path,
-					"method", r.Method,
-					"user_agent", userAgent,
-					"referer", r.Referer(),
-				)
+			logger.Warn("Honeypot triggered",
+				"ip", clientIP,
+				"path", path,
+				"action", rule.Action,
+				"decoy", rule.Decoy,
+				"method", r.Method,
+				"user_agent", userAgent,
+				"referer", r.Referer(),
+			)
 
+			switch rule.Decoy {
+			case DecoyLogin:
+				serveLoginDecoy(w, r)
+			case DecoyDrip:
+				select {
+				case drips <- struct{}{}:
+					serveDripDecoy(w, r)
+					<-drips
+				default:
+					http.NotFound(w, r)
+				}
+			default:
 				// Return 404 to make it look like the endpoint doesn't exist
 				http.NotFound(w, r)
-				return
 			}
-
-			// Not a honeypot path, continue to next middleware
-			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// DefaultHoneypotPaths returns common paths that attackers probe.
-func DefaultHoneypotPaths() []string {
-	return []string{
-		"/admin/login",
-		"/administrator",
-		"/cpanel",
-		"/phpMyAdmin",
-		"/.env",
-		"/.git/config",
-		"/backup.sql",
-		"/wp-login.php",
-		"/xmlrpc.php",
-		"/server-status",
-		"/config.php",
-		"/dashboard",
-		"/api/v1/admin",
-		"/robots.txt.bak",
-		"/wp-admin",
+// loginDecoyPage is a generic admin login form; %s receives the error message.
+const loginDecoyPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Log In</title>
+</head>
+<body>
+<form method="post" action="">
+<h1>Log In</h1>
+%s<p><label for="user">Username or Email Address</label><br><input type="text" name="log" id="user" autocomplete="username"></p>
+<p><label for="pass">Password</label><br><input type="password" name="pwd" id="pass" autocomplete="current-password"></p>
+<p><input type="submit" value="Log In"></p>
+</form>
+</body>
+</html>
+`
+
+// serveLoginDecoy serves a fake login page that rejects every submission.
+func serveLoginDecoy(w http.ResponseWriter, r *http.Request) {
+	message := ""
+	if r.Method == http.MethodPost {
+		message = "<p role=\"alert\"><strong>Error:</strong> The username or password you entered is incorrect.</p>\n"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, loginDecoyPage, message)
+}
+
+// serveDripDecoy trickles out a response one byte at a time to tie up scanners.
+func serveDripDecoy(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(dripInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(dripDuration)
+	defer deadline.Stop()
+
+	for {
+		if _, err := w.Write([]byte(" ")); err != nil {
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
 	}
 }
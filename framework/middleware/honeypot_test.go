@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"statigo/framework/security"
+)
+
+func TestHoneypotDoesNotBanTrustedProxies(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	proxies := []string{"127.0.0.1", "::1"}
+
+	configFS := fstest.MapFS{"honeypots.json": {Data: []byte(`{"rules": [
+		{ "path": "/.env*", "action": "ban", "duration": "24h" },
+		{ "path": "/xmlrpc.php", "action": "score", "points": 100 }
+	]}`)}}
+	registry, err := LoadHoneypotsFromJSON(configFS, "honeypots.json", logger)
+	if err != nil {
+		t.Fatalf("LoadHoneypotsFromJSON: %v", err)
+	}
+	resolver, err := security.NewClientIPResolver(security.ClientIPConfig{TrustedProxies: proxies})
+	if err != nil {
+		t.Fatalf("NewClientIPResolver: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		remoteAddr    string
+		forwardedFor  string
+		wantBannedIPs []string
+		wantClearIPs  []string
+	}{
+		{
+			name:         "proxy itself triggers ban rule",
+			path:         "/.env",
+			remoteAddr:   "127.0.0.1:40000",
+			wantClearIPs: []string{"127.0.0.1"},
+		},
+		{
+			name:         "proxy itself triggers score rule",
+			path:         "/xmlrpc.php",
+			remoteAddr:   "[::1]:40000",
+			wantClearIPs: []string{"::1"},
+		},
+		{
+			name:         "client behind proxy resolves to proxy",
+			path:         "/.env.local",
+			remoteAddr:   "127.0.0.1:40000",
+			forwardedFor: "::1",
+			wantClearIPs: []string{"127.0.0.1", "::1"},
+		},
+		{
+			name:          "client behind proxy triggers ban rule",
+			path:          "/.env",
+			remoteAddr:    "127.0.0.1:40000",
+			forwardedFor:  "203.0.113.9",
+			wantBannedIPs: []string{"203.0.113.9"},
+			wantClearIPs:  []string{"127.0.0.1"},
+		},
+		{
+			name:          "client behind proxy triggers score rule",
+			path:          "/xmlrpc.php",
+			remoteAddr:    "127.0.0.1:40000",
+			forwardedFor:  "203.0.113.9",
+			wantBannedIPs: []string{"203.0.113.9"},
+			wantClearIPs:  []string{"127.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			banList, err := security.NewIPBanList(filepath.Join(t.TempDir(), "banned-ips.json"), logger)
+			if err != nil {
+				t.Fatalf("NewIPBanList: %v", err)
+			}
+			defer banList.Stop()
+			if err := banList.SetAllowlist(proxies); err != nil {
+				t.Fatalf("SetAllowlist: %v", err)
+			}
+			scorer, err := security.NewThreatScorer(security.ThreatConfig{
+				Levels: []security.ThreatLevel{{Score: 60, Action: security.ActionBan, Duration: "1h"}},
+			}, banList, logger)
+			if err != nil {
+				t.Fatalf("NewThreatScorer: %v", err)
+			}
+			defer scorer.Stop()
+
+			handler := ClientIP(resolver)(HoneypotMiddleware(registry, scorer, banList, logger)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			for _, ip := range tt.wantBannedIPs {
+				if !banList.IsBanned(ip) {
+					t.Errorf("%s not banned", ip)
+				}
+			}
+			for _, ip := range tt.wantClearIPs {
+				if banList.IsBanned(ip) {
+					t.Errorf("%s banned", ip)
+				}
+			}
+			if want := len(tt.wantBannedIPs); banList.Count() != want {
+				t.Errorf("Count() = %d, want %d", banList.Count(), want)
+			}
+		})
+	}
+}
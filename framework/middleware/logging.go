@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"time"
 
+	fwctx "statigo/framework/context"
 	"statigo/framework/logger"
+	"statigo/framework/security"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code.
@@ -26,6 +28,11 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying writer so http.ResponseController can flush it.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // StructuredLogger creates a middleware that logs HTTP requests with structured logging.
 func StructuredLogger(log *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -35,6 +42,7 @@ func StructuredLogger(log *slog.Logger) func(next http.Handler) http.Handler {
 			// Generate and attach request ID
 			requestID := logger.GenerateRequestID()
 			ctx := logger.WithRequestID(r.Context(), requestID)
+			ctx = fwctx.WithDegraded(ctx)
 			r = r.WithContext(ctx)
 
 			// Wrap response writer to capture status code
@@ -49,19 +57,22 @@ func StructuredLogger(log *slog.Logger) func(next http.Handler) http.Handler {
 			// Log request details
 			duration := time.Since(start)
 
-			log.LogAttrs(
-				ctx,
-				slog.LevelInfo,
-				"HTTP request",
+			attrs := []slog.Attr{
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
 				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("client_ip", security.GetClientIP(r)),
 				slog.Int("status", wrapped.statusCode),
 				slog.Int64("bytes", wrapped.written),
 				slog.Duration("duration", duration),
 				slog.String("request_id", requestID),
 				slog.String("user_agent", r.UserAgent()),
-			)
+			}
+			// Flag responses rendered from fallback content
+			if reason := fwctx.GetDegraded(ctx); reason != "" {
+				attrs = append(attrs, slog.String("degraded", reason))
+			}
+			log.LogAttrs(ctx, slog.LevelInfo, "HTTP request", attrs...)
 		})
 	}
 }
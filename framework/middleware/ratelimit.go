@@ -1,20 +1,39 @@
 package middleware
 
 import (
+	"container/list"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
+
+	"statigo/framework/cache"
+	"statigo/framework/security"
 )
 
 // RateLimiterConfig configures the rate limiter middleware.
 type RateLimiterConfig struct {
-	RPS              int      // Requests per second for dynamic content
-	Burst            int      // Maximum burst size
-	StaticMultiplier int      // Multiplier for static asset limits (default: 10)
-	CrawlerBypass    bool     // Whether to bypass rate limiting for crawlers
-	Crawlers         []string // List of crawler user-agent substrings
+	RPS              int           // Requests per second for dynamic content
+	Burst            int           // Maximum burst size
+	StaticMultiplier int           // Multiplier for static asset limits (default: 10)
+	CrawlerBypass    bool          // Whether to bypass rate limiting for crawlers
+	Crawlers         []string      // List of crawler user-agent substrings
+	MaxClients       int           // Maximum number of tracked client buckets (default: 10000)
+	IdleTimeout      time.Duration // Drop buckets of clients idle this long (default: 10m)
+
+	// RouteLimit optionally returns a per-route override of the dynamic
+	// limits. scope identifies the route so its clients get separate buckets.
+	RouteLimit func(path string) (scope string, limit RouteRateLimit, ok bool)
+}
+
+// RouteRateLimit overrides the dynamic rate limit for a single route.
+type RouteRateLimit struct {
+	RPS   int
+	Burst int
 }
 
 // DefaultRateLimiterConfig returns default configuration.
@@ -23,6 +42,8 @@ func DefaultRateLimiterConfig() RateLimiterConfig {
 		RPS:              10,
 		Burst:            20,
 		StaticMultiplier: 10,
+		MaxClients:       10000,
+		IdleTimeout:      10 * time.Minute,
 		CrawlerBypass:    true,
 		Crawlers: []string{
 			"Googlebot",
@@ -50,19 +71,27 @@ func DefaultRateLimiterConfig() RateLimiterConfig {
 	}
 }
 
-// RateLimiter creates a middleware that limits requests using a token bucket algorithm.
+// RateLimiter creates a middleware that limits requests per client IP using
+// token buckets. Static assets get higher limits, and routes may override the
+// dynamic limit through RouteLimit.
 func RateLimiter(config RateLimiterConfig) func(http.Handler) http.Handler {
-	// Limiter for dynamic content (HTML pages, API endpoints)
-	dynamicLimiter := rate.NewLimiter(rate.Limit(config.RPS), config.Burst)
-
-	// Limiter for static assets (higher limits)
+	// Limits for static assets (higher than dynamic content)
 	staticMultiplier := config.StaticMultiplier
 	if staticMultiplier <= 0 {
 		staticMultiplier = 10
 	}
 	staticRPS := config.RPS * staticMultiplier
 	staticBurst := config.Burst * staticMultiplier
-	staticLimiter := rate.NewLimiter(rate.Limit(staticRPS), staticBurst)
+
+	maxClients := config.MaxClients
+	if maxClients <= 0 {
+		maxClients = 10000
+	}
+	idleTimeout := config.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = 10 * time.Minute
+	}
+	buckets := newClientBuckets(maxClients, idleTimeout)
 
 	// Build crawler lookup
 	crawlerLower := make([]string, len(config.Crawlers))
@@ -72,8 +101,8 @@ func RateLimiter(config RateLimiterConfig) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Bypass rate limiting for internal bootstrap requests
-			if r.Header.Get("X-Internal-Bootstrap") == "true" {
+			// Internal re-renders are never rate limited
+			if cache.IsRevalidation(r.Context()) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -89,38 +118,122 @@ func RateLimiter(config RateLimiterConfig) func(http.Handler) http.Handler {
 				}
 			}
 
-			var limiter *rate.Limiter
-			var limitRPS, limitBurst int
-
-			// Use higher limits for static assets
+			// Pick the bucket scope and limits for this request
+			scope := "dynamic"
+			limitRPS, limitBurst := config.RPS, config.Burst
 			if isStaticAsset(r.URL.Path) {
-				limiter = staticLimiter
-				limitRPS = staticRPS
-				limitBurst = staticBurst
-			} else {
-				limiter = dynamicLimiter
-				limitRPS = config.RPS
-				limitBurst = config.Burst
+				scope = "static"
+				limitRPS, limitBurst = staticRPS, staticBurst
+			} else if config.RouteLimit != nil {
+				if routeScope, limit, ok := config.RouteLimit(r.URL.Path); ok {
+					scope = "route:" + routeScope
+					limitRPS, limitBurst = limit.RPS, limit.Burst
+				}
 			}
 
-			if !limiter.Allow() {
-				// Calculate retry-after based on the rate limit
-				retryAfter := int(1.0 / float64(limitRPS))
+			now := time.Now()
+			clientIP := security.GetClientIP(r)
+			limiter := buckets.get(scope+"|"+clientIP, limitRPS, limitBurst, now)
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limitRPS))
+			w.Header().Set("X-RateLimit-Burst", strconv.Itoa(limitBurst))
+
+			reservation := limiter.ReserveN(now, 1)
+			if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+				reservation.CancelAt(now)
+
+				// Tell the client when its next token is available
+				retryAfter := int(math.Ceil(delay.Seconds()))
 				if retryAfter < 1 {
 					retryAfter = 1
 				}
 
 				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limitRPS))
-				w.Header().Set("X-RateLimit-Burst", strconv.Itoa(limitBurst))
+				w.Header().Set("X-RateLimit-Remaining", "0")
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
+
+			remaining := int(limiter.TokensAt(now))
+			if remaining < 0 {
+				remaining = 0
+			}
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// clientBucket is the token bucket of one client for one scope.
+type clientBucket struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// clientBuckets holds per-client token buckets in least-recently-used order.
+// Buckets idle for longer than idleTimeout are dropped, and the least
+// recently used bucket is evicted once maxClients is reached.
+type clientBuckets struct {
+	mu          sync.Mutex
+	entries     map[string]*list.Element
+	lru         *list.List // Front is most recently used
+	maxClients  int
+	idleTimeout time.Duration
+}
+
+// newClientBuckets creates an empty bucket set.
+func newClientBuckets(maxClients int, idleTimeout time.Duration) *clientBuckets {
+	return &clientBuckets{
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		maxClients:  maxClients,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// get returns the limiter for key, creating it with the given limits if needed.
+func (cb *clientBuckets) get(key string, rps, burst int, now time.Time) *rate.Limiter {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// Drop idle buckets from the back of the list
+	for oldest := cb.lru.Back(); oldest != nil; oldest = cb.lru.Back() {
+		bucket := oldest.Value.(*clientBucket)
+		if now.Sub(bucket.lastSeen) < cb.idleTimeout {
+			break
+		}
+		cb.remove(oldest)
+	}
+
+	if element, exists := cb.entries[key]; exists {
+		bucket := element.Value.(*clientBucket)
+		bucket.lastSeen = now
+		cb.lru.MoveToFront(element)
+		return bucket.limiter
+	}
+
+	// Bound memory by evicting the least recently used client
+	if cb.lru.Len() >= cb.maxClients {
+		cb.remove(cb.lru.Back())
+	}
+
+	bucket := &clientBucket{
+		key:      key,
+		limiter:  rate.NewLimiter(rate.Limit(rps), burst),
+		lastSeen: now,
+	}
+	cb.entries[key] = cb.lru.PushFront(bucket)
+	return bucket.limiter
+}
+
+// remove deletes a bucket from the set.
+func (cb *clientBuckets) remove(element *list.Element) {
+	cb.lru.Remove(element)
+	delete(cb.entries, element.Value.(*clientBucket).key)
+}
+
 // isStaticAsset checks if the request path is for a static asset.
 func isStaticAsset(path string) bool {
 	staticPrefixes := []string{"/assets/", "/static/", "/favicon.ico", "/robots.txt", "/manifest.json"}
@@ -5,57 +5,186 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
+
+	"statigo/framework/security"
 )
 
-// RedirectConfig represents the redirect configuration structure.
+// RedirectConfig represents the legacy redirect configuration structure.
 // Key: target URL (where to redirect to)
 // Value: array of source URLs (that should redirect to the target)
 type RedirectConfig map[string][]string
 
-// PatternRedirect represents a pattern-based redirect with regex matching.
-type PatternRedirect struct {
-	pattern *regexp.Regexp // Compiled regex pattern for matching
-	target  string         // Target URL template with {slug} placeholders
-	source  string         // Original source pattern for logging
+// RedirectRulesConfig represents the extended redirect configuration:
+//
+//	{"rules": [{"from": "/old/{year}/{slug}", "to": "/blogs/{slug}", "status": 302}]}
+type RedirectRulesConfig struct {
+	Rules []RedirectRule `json:"rules"`
+}
+
+// RedirectRule is a single redirect from the extended configuration format.
+type RedirectRule struct {
+	From          string `json:"from"`                    // Source path; "{name}" placeholders and a trailing "*" splat are allowed
+	To            string `json:"to"`                      // Target URL; may reference placeholders and "*"
+	Status        int    `json:"status,omitempty"`        // 301 (default), 302, 307, 308 or 410 (Gone)
+	Host          string `json:"host,omitempty"`          // Only match requests for this host (placeholders allowed)
+	Regex         bool   `json:"regex,omitempty"`         // Treat From as a regular expression; named groups become placeholders
+	PreserveQuery *bool  `json:"preserveQuery,omitempty"` // Append the request query string to the target (default: true)
+}
+
+// RedirectResult describes where and how a request should be redirected.
+type RedirectResult struct {
+	Target        string
+	Status        int
+	PreserveQuery bool
+}
+
+// compiledRedirect is a redirect rule prepared for matching.
+type compiledRedirect struct {
+	pattern       *regexp.Regexp // Path pattern (nil for exact static redirects)
+	host          *regexp.Regexp // Host pattern (nil matches any host)
+	target        string         // Target URL template with placeholders
+	source        string         // Original source pattern for logging
+	rule          RedirectRule   // Rule as configured
+	status        int
+	preserveQuery bool
 }
 
 // RedirectRegistry maintains an optimized lookup table for redirects.
 type RedirectRegistry struct {
-	// Static redirects: source URL -> target URL (O(1) lookups)
-	staticRedirects map[string]string
-	// Pattern-based redirects with dynamic slug matching
-	patternRedirects []PatternRedirect
-	logger           *slog.Logger
+	// Static redirects: source URL -> redirect (O(1) lookups)
+	staticRedirects map[string]*compiledRedirect
+	// Pattern, regex and host-based redirects, matched in configuration order
+	patternRedirects []*compiledRedirect
+	// Runtime redirects recorded on disk (optional)
+	store  *RedirectStore
+	logger *slog.Logger
 }
 
+// maxRedirectHops bounds how many redirects are followed when collapsing chains.
+const maxRedirectHops = 10
+
+// splatName is the capture group name used for a trailing "*" in patterns.
+const splatName = "splat"
+
+// placeholderPattern matches escaped {name} placeholders in a quoted pattern.
+var placeholderPattern = regexp.MustCompile(`\\\{([A-Za-z_][A-Za-z0-9_]*)\\\}`)
+
 // NewRedirectRegistry creates a new redirect registry.
 func NewRedirectRegistry(logger *slog.Logger) *RedirectRegistry {
 	return &RedirectRegistry{
-		staticRedirects:  make(map[string]string),
-		patternRedirects: make([]PatternRedirect, 0),
+		staticRedirects:  make(map[string]*compiledRedirect),
+		patternRedirects: make([]*compiledRedirect, 0),
 		logger:           logger,
 	}
 }
 
-// isPatternURL checks if a URL contains dynamic placeholders like {slug}.
+// isPatternURL checks if a URL contains dynamic placeholders like {slug}
+// or ends with a "*" splat.
 func isPatternURL(url string) bool {
-	return strings.Contains(url, "{") && strings.Contains(url, "}")
+	return (strings.Contains(url, "{") && strings.Contains(url, "}")) || strings.HasSuffix(url, "*")
 }
 
-// patternToRegex converts a URL pattern with {slug} to a compiled regex.
+// patternToRegex converts a URL pattern with {name} placeholders and an
+// optional trailing "*" splat to a compiled, anchored regex.
 func patternToRegex(pattern string) (*regexp.Regexp, error) {
+	splat := strings.HasSuffix(pattern, "*")
+	pattern = strings.TrimSuffix(pattern, "*")
+
 	// Escape special regex characters except for our placeholders
 	regexPattern := regexp.QuoteMeta(pattern)
-	// Replace escaped \{slug\} with a named capture group
-	regexPattern = strings.ReplaceAll(regexPattern, `\{slug\}`, `(?P<slug>[^/]+)`)
+	// Replace escaped \{name\} with named capture groups
+	regexPattern = placeholderPattern.ReplaceAllString(regexPattern, `(?P<$1>[^/]+)`)
+	if splat {
+		regexPattern += `(?P<` + splatName + `>.*)`
+	}
 	// Anchor the pattern to match the entire path
 	regexPattern = "^" + regexPattern + "$"
 	return regexp.Compile(regexPattern)
 }
 
+// compileRule validates a redirect rule and prepares it for matching.
+func compileRule(rule RedirectRule) (*compiledRedirect, error) {
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect, http.StatusGone:
+	default:
+		return nil, fmt.Errorf("unsupported redirect status %d", status)
+	}
+
+	if rule.From == "" {
+		return nil, fmt.Errorf("redirect rule has no source")
+	}
+	if rule.To == "" && status != http.StatusGone {
+		return nil, fmt.Errorf("redirect rule %s has no target", rule.From)
+	}
+
+	compiled := &compiledRedirect{
+		target:        rule.To,
+		source:        rule.From,
+		rule:          rule,
+		status:        status,
+		preserveQuery: rule.PreserveQuery == nil || *rule.PreserveQuery,
+	}
+
+	var err error
+	switch {
+	case rule.Regex:
+		compiled.pattern, err = regexp.Compile(rule.From)
+	case isPatternURL(rule.From) || rule.Host != "":
+		compiled.pattern, err = patternToRegex(rule.From)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid source pattern %s: %w", rule.From, err)
+	}
+
+	if rule.Host != "" {
+		if compiled.host, err = patternToRegex(strings.ToLower(rule.Host)); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %s: %w", rule.Host, err)
+		}
+	}
+
+	return compiled, nil
+}
+
+// parseRedirectRules reads either the extended {"rules": [...]} format or
+// the legacy {"target": ["source", ...]} format.
+func parseRedirectRules(data []byte) ([]RedirectRule, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	// A legacy "rules" target would hold strings, so only objects select the extended format
+	if _, ok := probe["rules"]; ok {
+		var config RedirectRulesConfig
+		if err := json.Unmarshal(data, &config); err == nil {
+			return config.Rules, nil
+		}
+	}
+
+	var legacy RedirectConfig
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	var rules []RedirectRule
+	for targetURL, sourceURLs := range legacy {
+		for _, sourceURL := range sourceURLs {
+			rules = append(rules, RedirectRule{From: sourceURL, To: targetURL})
+		}
+	}
+	return rules, nil
+}
+
 // LoadRedirectsFromJSON loads redirect configurations from a JSON file.
 func LoadRedirectsFromJSON(configFS fs.FS, filePath string, logger *slog.Logger) (*RedirectRegistry, error) {
 	registry := NewRedirectRegistry(logger)
@@ -67,8 +196,8 @@ func LoadRedirectsFromJSON(configFS fs.FS, filePath string, logger *slog.Logger)
 	}
 
 	// Parse JSON
-	var config RedirectConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	rules, err := parseRedirectRules(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse redirects JSON: %w", err)
 	}
 
@@ -78,48 +207,44 @@ func LoadRedirectsFromJSON(configFS fs.FS, filePath string, logger *slog.Logger)
 	staticCount := 0
 	patternCount := 0
 
-	for targetURL, sourceURLs := range config {
-		for _, sourceURL := range sourceURLs {
-			// Check if this is a pattern-based redirect
-			if isPatternURL(sourceURL) {
-				// Compile the pattern to regex
-				pattern, err := patternToRegex(sourceURL)
-				if err != nil {
-					logger.Error("Failed to compile redirect pattern, skipping",
-						"source", sourceURL,
-						"target", targetURL,
-						"error", err)
-					continue
-				}
+	for _, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			logger.Error("Failed to compile redirect rule, skipping",
+				"source", rule.From,
+				"target", rule.To,
+				"error", err)
+			continue
+		}
 
-				// Add to pattern redirects
-				registry.patternRedirects = append(registry.patternRedirects, PatternRedirect{
-					pattern: pattern,
-					target:  targetURL,
-					source:  sourceURL,
-				})
-				patternCount++
-
-				logger.Debug("Registered pattern redirect",
-					"source", sourceURL,
-					"target", targetURL)
-			} else {
-				// Static redirect - check for duplicates
-				if existingTarget, exists := registry.staticRedirects[sourceURL]; exists {
-					logger.Warn("Duplicate redirect source URL found, overwriting",
-						"source", sourceURL,
-						"old_target", existingTarget,
-						"new_target", targetURL)
-				}
+		if compiled.pattern != nil {
+			// Add to pattern redirects
+			registry.patternRedirects = append(registry.patternRedirects, compiled)
+			patternCount++
 
-				registry.staticRedirects[sourceURL] = targetURL
-				staticCount++
+			logger.Debug("Registered pattern redirect",
+				"source", rule.From,
+				"target", rule.To,
+				"host", rule.Host,
+				"status", compiled.status)
+			continue
+		}
 
-				logger.Debug("Registered static redirect",
-					"source", sourceURL,
-					"target", targetURL)
-			}
+		// Static redirect - check for duplicates
+		if existing, exists := registry.staticRedirects[rule.From]; exists {
+			logger.Warn("Duplicate redirect source URL found, overwriting",
+				"source", rule.From,
+				"old_target", existing.target,
+				"new_target", rule.To)
 		}
+
+		registry.staticRedirects[rule.From] = compiled
+		staticCount++
+
+		logger.Debug("Registered static redirect",
+			"source", rule.From,
+			"target", rule.To,
+			"status", compiled.status)
 	}
 
 	logger.Info("Successfully loaded redirects",
@@ -130,40 +255,182 @@ func LoadRedirectsFromJSON(configFS fs.FS, filePath string, logger *slog.Logger)
 	return registry, nil
 }
 
-// GetRedirectTarget returns the target URL for a given source URL.
-// Returns empty string if no redirect exists.
+// SetStore attaches a runtime redirect store. Its redirects are merged with
+// the configured ones on every lookup, so new entries apply without a restart.
+func (rr *RedirectRegistry) SetStore(store *RedirectStore) {
+	rr.store = store
+}
+
+// GetRedirectTarget returns the final target URL for a given source URL,
+// ignoring host-based rules. Returns empty string if no redirect exists.
 func (rr *RedirectRegistry) GetRedirectTarget(sourceURL string) string {
+	if result, ok := rr.Match("", sourceURL); ok {
+		return result.Target
+	}
+	return ""
+}
+
+// Match returns the redirect for a request host and path, following chains
+// (a -> b -> c) so clients get a single hop. The status and query handling
+// of the first hop apply, unless a later hop is Gone. Returns false if no
+// redirect exists or the chain loops.
+func (rr *RedirectRegistry) Match(host, path string) (RedirectResult, bool) {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	result, ok := rr.lookup(host, path)
+	if !ok {
+		return RedirectResult{}, false
+	}
+
+	seen := map[string]bool{path: true}
+	for hops := 1; result.Status != http.StatusGone && hops < maxRedirectHops; hops++ {
+		// Only local targets can chain into further redirects
+		if !strings.HasPrefix(result.Target, "/") {
+			break
+		}
+		next, ok := rr.lookup(host, result.Target)
+		if !ok {
+			break
+		}
+		if seen[next.Target] {
+			rr.logger.Warn("Redirect loop detected, ignoring redirect",
+				"source", path,
+				"target", result.Target)
+			return RedirectResult{}, false
+		}
+		seen[result.Target] = true
+
+		if next.Status == http.StatusGone {
+			result.Status = http.StatusGone
+		}
+		result.Target = next.Target
+	}
+
+	return result, true
+}
+
+// lookup returns the direct redirect for a host and path without following chains.
+func (rr *RedirectRegistry) lookup(host, path string) (RedirectResult, bool) {
 	// First, check static redirects (O(1) lookup)
-	if target, exists := rr.staticRedirects[sourceURL]; exists {
-		return target
+	if redirect, exists := rr.staticRedirects[path]; exists {
+		return redirect.result(redirect.target), true
+	}
+
+	// Then, check redirects recorded at runtime
+	if rr.store != nil {
+		if target, exists := rr.store.Get(path); exists {
+			return RedirectResult{Target: target, Status: http.StatusMovedPermanently, PreserveQuery: true}, true
+		}
 	}
 
-	// If no static match, check pattern redirects
-	for _, patternRedirect := range rr.patternRedirects {
-		if matches := patternRedirect.pattern.FindStringSubmatch(sourceURL); matches != nil {
-			// Extract captured groups
-			target := patternRedirect.target
+	// Finally, check pattern redirects in configuration order
+	for _, redirect := range rr.patternRedirects {
+		if target, ok := redirect.match(host, path); ok {
+			return redirect.result(target), true
+		}
+	}
 
-			// Replace {slug} in target with captured value
-			for i, name := range patternRedirect.pattern.SubexpNames() {
-				if i > 0 && i < len(matches) && name == "slug" {
-					target = strings.ReplaceAll(target, "{slug}", matches[i])
-				}
+	return RedirectResult{}, false
+}
+
+// match checks a pattern redirect against a host and path and returns the
+// target with placeholders filled in.
+func (cr *compiledRedirect) match(host, path string) (string, bool) {
+	values := make(map[string]string)
+
+	if cr.host != nil {
+		hostMatches := cr.host.FindStringSubmatch(host)
+		if hostMatches == nil {
+			return "", false
+		}
+		collectGroups(cr.host, hostMatches, values)
+	}
+
+	matches := cr.pattern.FindStringSubmatch(path)
+	if matches == nil {
+		return "", false
+	}
+	collectGroups(cr.pattern, matches, values)
+
+	target := cr.target
+	for name, value := range values {
+		if name == splatName {
+			target = strings.ReplaceAll(target, "*", value)
+			continue
+		}
+		target = strings.ReplaceAll(target, "{"+name+"}", value)
+	}
+	return target, true
+}
+
+// result builds a redirect result for a resolved target.
+func (cr *compiledRedirect) result(target string) RedirectResult {
+	return RedirectResult{
+		Target:        target,
+		Status:        cr.status,
+		PreserveQuery: cr.preserveQuery,
+	}
+}
+
+// collectGroups stores the named capture groups of a match in values.
+func collectGroups(pattern *regexp.Regexp, matches []string, values map[string]string) {
+	for i, name := range pattern.SubexpNames() {
+		if i > 0 && i < len(matches) && name != "" {
+			values[name] = matches[i]
+		}
+	}
+}
+
+// Rules returns every redirect as a rule, in the order they are matched:
+// static redirects sorted by source, redirects recorded at runtime, then
+// pattern redirects in configuration order. Chains of static and runtime
+// redirects are collapsed to their final target.
+func (rr *RedirectRegistry) Rules() []RedirectRule {
+	collapse := func(rule RedirectRule) RedirectRule {
+		if result, ok := rr.Match("", rule.From); ok {
+			rule.To = result.Target
+			if result.Status == http.StatusGone {
+				rule.Status = http.StatusGone
 			}
+		}
+		return rule
+	}
+
+	var rules []RedirectRule
+	for _, source := range slices.Sorted(maps.Keys(rr.staticRedirects)) {
+		rules = append(rules, collapse(rr.staticRedirects[source].rule))
+	}
 
-			return target
+	if rr.store != nil {
+		stored := rr.store.All()
+		for _, source := range slices.Sorted(maps.Keys(stored)) {
+			if _, exists := rr.staticRedirects[source]; exists {
+				continue
+			}
+			rules = append(rules, collapse(RedirectRule{From: source, To: stored[source]}))
 		}
 	}
 
-	return ""
+	for _, redirect := range rr.patternRedirects {
+		rules = append(rules, redirect.rule)
+	}
+	return rules
 }
 
-// Count returns the total number of redirects (static + pattern).
+// Count returns the total number of redirects (static + pattern + stored).
 func (rr *RedirectRegistry) Count() int {
-	return len(rr.staticRedirects) + len(rr.patternRedirects)
+	count := len(rr.staticRedirects) + len(rr.patternRedirects)
+	if rr.store != nil {
+		count += rr.store.Count()
+	}
+	return count
 }
 
-// RedirectMiddleware handles URL redirects using a 301 Moved Permanently status.
+// RedirectMiddleware handles URL redirects using each rule's status code
+// (301 Moved Permanently by default, or 410 Gone for removed pages).
 func RedirectMiddleware(registry *RedirectRegistry, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -175,28 +442,43 @@ func RedirectMiddleware(registry *RedirectRegistry, logger *slog.Logger) func(ht
 				lookupPath = strings.TrimSuffix(lookupPath, "/")
 			}
 
-			// Check if a redirect exists for this path
-			if targetURL := registry.GetRedirectTarget(lookupPath); targetURL != "" {
-				// Log the redirect
-				logger.Info("Redirecting request",
+			// Check if a redirect exists for this host and path
+			result, found := registry.Match(r.Host, lookupPath)
+			if !found {
+				// No redirect found, continue to next handler
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if result.Status == http.StatusGone {
+				logger.Info("Serving gone page",
 					"source", requestPath,
-					"target", targetURL,
 					"method", r.Method,
-					"remote_addr", r.RemoteAddr)
+					"ip", security.GetClientIP(r))
 
-				// Preserve query string if present
-				targetWithQuery := targetURL
-				if r.URL.RawQuery != "" {
-					targetWithQuery = targetURL + "?" + r.URL.RawQuery
-				}
-
-				// 301 Moved Permanently
-				http.Redirect(w, r, targetWithQuery, http.StatusMovedPermanently)
+				http.Error(w, "Gone", http.StatusGone)
 				return
 			}
 
-			// No redirect found, continue to next handler
-			next.ServeHTTP(w, r)
+			// Log the redirect
+			logger.Info("Redirecting request",
+				"source", requestPath,
+				"target", result.Target,
+				"status", result.Status,
+				"method", r.Method,
+				"ip", security.GetClientIP(r))
+
+			// Preserve query string if present and enabled
+			targetWithQuery := result.Target
+			if result.PreserveQuery && r.URL.RawQuery != "" {
+				separator := "?"
+				if strings.Contains(targetWithQuery, "?") {
+					separator = "&"
+				}
+				targetWithQuery = targetWithQuery + separator + r.URL.RawQuery
+			}
+
+			http.Redirect(w, r, targetWithQuery, result.Status)
 		})
 	}
 }
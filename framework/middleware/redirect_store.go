@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RedirectStore is a writable, file-backed set of redirects recorded at
+// runtime (e.g. when a post's slug changes). Keys are source URLs, values
+// are target URLs.
+type RedirectStore struct {
+	mu        sync.RWMutex
+	redirects map[string]string
+	filePath  string
+	logger    *slog.Logger
+}
+
+// NewRedirectStore creates a redirect store backed by the given file.
+func NewRedirectStore(filePath string, logger *slog.Logger) (*RedirectStore, error) {
+	store := &RedirectStore{
+		redirects: make(map[string]string),
+		filePath:  filePath,
+		logger:    logger,
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Add records a redirect from source to target and persists it.
+// Existing redirects pointing at source are re-pointed at target so chains
+// collapse to a single hop, and any redirect away from target is dropped
+// because target is live again. Nothing changes if persisting fails.
+func (s *RedirectStore) Add(source, target string) error {
+	if source == target {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := maps.Clone(s.redirects)
+	delete(s.redirects, target)
+	for src, dst := range s.redirects {
+		if dst == source {
+			s.redirects[src] = target
+		}
+	}
+	s.redirects[source] = target
+
+	if err := s.save(); err != nil {
+		s.redirects = previous
+		return err
+	}
+
+	s.logger.Info("Recorded redirect",
+		"source", source,
+		"target", target,
+	)
+	return nil
+}
+
+// Remove deletes the redirect for source and persists the change. Nothing
+// changes if persisting fails.
+func (s *RedirectStore) Remove(source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, exists := s.redirects[source]
+	if !exists {
+		return nil
+	}
+	delete(s.redirects, source)
+
+	if err := s.save(); err != nil {
+		s.redirects[source] = target
+		return err
+	}
+
+	s.logger.Info("Removed redirect",
+		"source", source,
+		"target", target,
+	)
+	return nil
+}
+
+// Get returns the target for a source URL.
+func (s *RedirectStore) Get(source string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	target, exists := s.redirects[source]
+	return target, exists
+}
+
+// All returns a copy of the stored redirects.
+func (s *RedirectStore) All() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return maps.Clone(s.redirects)
+}
+
+// Count returns the number of stored redirects.
+func (s *RedirectStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.redirects)
+}
+
+// save writes the redirects to a temporary file and renames it into place.
+func (s *RedirectStore) save() error {
+	data, err := json.MarshalIndent(s.redirects, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode redirect store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), ".redirects-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create redirect store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write redirect store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write redirect store: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return fmt.Errorf("failed to replace redirect store: %w", err)
+	}
+	return nil
+}
+
+// load reads the redirects from disk.
+func (s *RedirectStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File doesn't exist yet, that's fine
+		}
+		return fmt.Errorf("failed to read redirect store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.redirects); err != nil {
+		return fmt.Errorf("failed to parse redirect store: %w", err)
+	}
+	if s.redirects == nil {
+		s.redirects = make(map[string]string)
+	}
+
+	s.logger.Info("Loaded redirect store", "count", len(s.redirects), "file", s.filePath)
+	return nil
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedirectStoreAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		adds [][2]string // source, target
+		want map[string]string
+	}{
+		{
+			name: "single redirect",
+			adds: [][2]string{{"/blog/a", "/blog/b"}},
+			want: map[string]string{"/blog/a": "/blog/b"},
+		},
+		{
+			name: "same source and target",
+			adds: [][2]string{{"/blog/a", "/blog/a"}},
+			want: map[string]string{},
+		},
+		{
+			name: "chain collapses to one hop",
+			adds: [][2]string{{"/blog/a", "/blog/b"}, {"/blog/b", "/blog/c"}},
+			want: map[string]string{"/blog/a": "/blog/c", "/blog/b": "/blog/c"},
+		},
+		{
+			name: "long chain collapses to one hop",
+			adds: [][2]string{{"/blog/a", "/blog/b"}, {"/blog/b", "/blog/c"}, {"/blog/c", "/blog/d"}},
+			want: map[string]string{"/blog/a": "/blog/d", "/blog/b": "/blog/d", "/blog/c": "/blog/d"},
+		},
+		{
+			name: "renaming back drops the loop",
+			adds: [][2]string{{"/blog/a", "/blog/b"}, {"/blog/b", "/blog/a"}},
+			want: map[string]string{"/blog/b": "/blog/a"},
+		},
+		{
+			name: "renaming back through a chain drops the loop",
+			adds: [][2]string{{"/blog/a", "/blog/b"}, {"/blog/b", "/blog/c"}, {"/blog/c", "/blog/a"}},
+			want: map[string]string{"/blog/b": "/blog/a", "/blog/c": "/blog/a"},
+		},
+		{
+			name: "source re-pointed",
+			adds: [][2]string{{"/blog/a", "/blog/b"}, {"/blog/a", "/blog/c"}},
+			want: map[string]string{"/blog/a": "/blog/c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			path := filepath.Join(t.TempDir(), "redirects.json")
+			store, err := NewRedirectStore(path, logger)
+			if err != nil {
+				t.Fatalf("NewRedirectStore: %v", err)
+			}
+
+			for _, add := range tt.adds {
+				if err := store.Add(add[0], add[1]); err != nil {
+					t.Fatalf("Add(%s, %s): %v", add[0], add[1], err)
+				}
+			}
+
+			if got := store.All(); !maps.Equal(got, tt.want) {
+				t.Errorf("All() = %v, want %v", got, tt.want)
+			}
+			for source, target := range tt.want {
+				if target == source {
+					t.Errorf("redirect %s points at itself", source)
+				}
+				if _, ok := tt.want[target]; ok {
+					t.Errorf("redirect %s -> %s is not a single hop", source, target)
+				}
+			}
+
+			// What is on disk matches what is in memory
+			reloaded, err := NewRedirectStore(path, logger)
+			if err != nil {
+				t.Fatalf("NewRedirectStore (reload): %v", err)
+			}
+			if got := reloaded.All(); !maps.Equal(got, tt.want) {
+				t.Errorf("reloaded All() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectStoreRollsBackFailedSaves(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewRedirectStore(filepath.Join(dir, "redirects.json"), logger)
+	if err != nil {
+		t.Fatalf("NewRedirectStore: %v", err)
+	}
+	if err := store.Add("/blog/a", "/blog/b"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	want := store.All()
+
+	// Saving fails once the directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Add("/blog/b", "/blog/c"); err == nil {
+		t.Error("Add succeeded without a directory to save to")
+	}
+	if got := store.All(); !maps.Equal(got, want) {
+		t.Errorf("after failed Add, All() = %v, want %v", got, want)
+	}
+
+	if err := store.Remove("/blog/a"); err == nil {
+		t.Error("Remove succeeded without a directory to save to")
+	}
+	if got := store.All(); !maps.Equal(got, want) {
+		t.Errorf("after failed Remove, All() = %v, want %v", got, want)
+	}
+}
@@ -3,61 +3,164 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"statigo/framework/security"
 )
 
-// SecurityHeadersConfig configures the security headers middleware.
+// SecurityHeadersConfig configures the security headers middleware. Empty
+// values leave the corresponding header out.
 type SecurityHeadersConfig struct {
-	HSTSMaxAge            int      // HSTS max-age in seconds (default: 31536000)
-	FrameOptions          string   // X-Frame-Options value (default: "DENY")
-	ContentTypeOptions    string   // X-Content-Type-Options value (default: "nosniff")
-	ReferrerPolicy        string   // Referrer-Policy value (default: "strict-origin-when-cross-origin")
-	ContentSecurityPolicy string   // Custom CSP (optional)
-	AllowedImageSources   []string // Additional image sources for CSP
+	HSTSMaxAge                int                 // HSTS max-age in seconds, 0 disables HSTS (default: 31536000)
+	HSTSIncludeSubDomains     bool                // Apply HSTS to all subdomains (default: true)
+	HSTSPreload               bool                // Request inclusion in browser preload lists
+	FrameOptions              string              // X-Frame-Options value (default: "DENY")
+	ContentTypeOptions        string              // X-Content-Type-Options value (default: "nosniff")
+	ReferrerPolicy            string              // Referrer-Policy value (default: "strict-origin-when-cross-origin")
+	PermissionsPolicy         string              // Permissions-Policy value (default: "geolocation=(), microphone=(), camera=()")
+	CrossOriginOpenerPolicy   string              // Cross-Origin-Opener-Policy value (default: "same-origin")
+	CrossOriginEmbedderPolicy string              // Cross-Origin-Embedder-Policy value (e.g. "require-corp")
+	CrossOriginResourcePolicy string              // Cross-Origin-Resource-Policy value (default: "same-origin")
+	CSP                       *security.CSPConfig // Content-Security-Policy (optional)
+
+	// RouteHeaders optionally returns per-route overrides. scope identifies
+	// the route so its headers are only computed once.
+	RouteHeaders func(path string) (scope string, headers RouteSecurityHeaders, ok bool)
+}
+
+// RouteSecurityHeaders overrides security headers for a single route. Nil
+// fields keep the global value and empty strings remove the header.
+type RouteSecurityHeaders struct {
+	FrameOptions              *string
+	FrameAncestors            []string // Replaces the CSP frame-ancestors sources
+	ReferrerPolicy            *string
+	PermissionsPolicy         *string
+	CrossOriginOpenerPolicy   *string
+	CrossOriginEmbedderPolicy *string
+	CrossOriginResourcePolicy *string
 }
 
 // DefaultSecurityHeadersConfig returns default configuration.
 func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
 	return SecurityHeadersConfig{
-		HSTSMaxAge:         31536000,
-		FrameOptions:       "DENY",
-		ContentTypeOptions: "nosniff",
-		ReferrerPolicy:     "strict-origin-when-cross-origin",
+		HSTSMaxAge:                31536000,
+		HSTSIncludeSubDomains:     true,
+		FrameOptions:              "DENY",
+		ContentTypeOptions:        "nosniff",
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		PermissionsPolicy:         "geolocation=(), microphone=(), camera=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+}
+
+// securityHeader is a header name and value.
+type securityHeader struct {
+	name  string
+	value string
+}
+
+// securityHeaderSet holds the headers sent for a route and the handler
+// (with its CSP middleware) that serves it.
+type securityHeaderSet struct {
+	headers []securityHeader
+	handler http.Handler
+}
+
+// headers returns the configured headers other than HSTS and CSP.
+func (c SecurityHeadersConfig) headers() []securityHeader {
+	return []securityHeader{
+		{"X-Frame-Options", c.FrameOptions},
+		{"X-Content-Type-Options", c.ContentTypeOptions},
+		{"Referrer-Policy", c.ReferrerPolicy},
+		{"Permissions-Policy", c.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", c.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Embedder-Policy", c.CrossOriginEmbedderPolicy},
+		{"Cross-Origin-Resource-Policy", c.CrossOriginResourcePolicy},
 	}
 }
 
-// SecurityHeaders middleware adds security headers to responses.
+// hsts returns the Strict-Transport-Security value, or "" when disabled.
+func (c SecurityHeadersConfig) hsts() string {
+	if c.HSTSMaxAge <= 0 {
+		return ""
+	}
+	value := "max-age=" + strconv.Itoa(c.HSTSMaxAge)
+	if c.HSTSIncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if c.HSTSPreload {
+		value += "; preload"
+	}
+	return value
+}
+
+// withRoute returns a copy of the config with a route's overrides applied.
+func (c SecurityHeadersConfig) withRoute(route RouteSecurityHeaders) SecurityHeadersConfig {
+	override := func(value *string, target *string) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	override(route.FrameOptions, &c.FrameOptions)
+	override(route.ReferrerPolicy, &c.ReferrerPolicy)
+	override(route.PermissionsPolicy, &c.PermissionsPolicy)
+	override(route.CrossOriginOpenerPolicy, &c.CrossOriginOpenerPolicy)
+	override(route.CrossOriginEmbedderPolicy, &c.CrossOriginEmbedderPolicy)
+	override(route.CrossOriginResourcePolicy, &c.CrossOriginResourcePolicy)
+
+	if route.FrameAncestors != nil && c.CSP != nil {
+		csp := *c.CSP
+		csp.FrameAncestors = route.FrameAncestors
+		c.CSP = &csp
+	}
+	return c
+}
+
+// headerSet builds the headers and handler for the config.
+func (c SecurityHeadersConfig) headerSet(next http.Handler) *securityHeaderSet {
+	set := &securityHeaderSet{handler: next}
+	for _, header := range c.headers() {
+		if header.value != "" {
+			set.headers = append(set.headers, header)
+		}
+	}
+	if c.CSP != nil {
+		set.handler = CSP(*c.CSP)(next)
+	}
+	return set
+}
+
+// SecurityHeaders middleware adds security headers to responses. HSTS is
+// only sent over HTTPS, as browsers ignore it on plain HTTP.
 func SecurityHeaders(config SecurityHeadersConfig) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// HSTS
-			if config.HSTSMaxAge > 0 {
-				w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-			}
+	hsts := config.hsts()
 
-			// X-Frame-Options
-			if config.FrameOptions != "" {
-				w.Header().Set("X-Frame-Options", config.FrameOptions)
-			}
+	return func(next http.Handler) http.Handler {
+		global := config.headerSet(next)
+		var routes sync.Map // scope -> *securityHeaderSet
 
-			// X-Content-Type-Options
-			if config.ContentTypeOptions != "" {
-				w.Header().Set("X-Content-Type-Options", config.ContentTypeOptions)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			set := global
+			if config.RouteHeaders != nil {
+				if scope, route, ok := config.RouteHeaders(r.URL.Path); ok {
+					cached, found := routes.Load(scope)
+					if !found {
+						cached, _ = routes.LoadOrStore(scope, config.withRoute(route).headerSet(next))
+					}
+					set = cached.(*securityHeaderSet)
+				}
 			}
 
-			// Referrer-Policy
-			if config.ReferrerPolicy != "" {
-				w.Header().Set("Referrer-Policy", config.ReferrerPolicy)
+			if hsts != "" && security.IsHTTPS(r) {
+				w.Header().Set("Strict-Transport-Security", hsts)
 			}
-
-			// Content-Security-Policy
-			if config.ContentSecurityPolicy != "" {
-				w.Header().Set("Content-Security-Policy", config.ContentSecurityPolicy)
+			for _, header := range set.headers {
+				w.Header().Set(header.name, header.value)
 			}
 
-			// Permissions-Policy
-			w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-
-			next.ServeHTTP(w, r)
+			set.handler.ServeHTTP(w, r)
 		})
 	}
 }
@@ -71,7 +174,7 @@ func SecurityHeadersSimple() func(http.Handler) http.Handler {
 func IPBanMiddleware(banList IPBanList, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := GetClientIP(r)
+			clientIP := security.GetClientIP(r)
 
 			if banList.IsBanned(clientIP) {
 				logger.Info("Blocked request from banned IP",
@@ -93,32 +196,3 @@ func IPBanMiddleware(banList IPBanList, logger *slog.Logger) func(http.Handler)
 type IPBanList interface {
 	IsBanned(ip string) bool
 }
-
-// GetClientIP extracts the real client IP from the request.
-func GetClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (common with reverse proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		for i := 0; i < len(xff); i++ {
-			if xff[i] == ',' {
-				return xff[:i]
-			}
-		}
-		return xff
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	addr := r.RemoteAddr
-	for i := len(addr) - 1; i >= 0; i-- {
-		if addr[i] == ':' {
-			return addr[:i]
-		}
-	}
-
-	return addr
-}
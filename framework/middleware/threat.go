@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"statigo/framework/cache"
+	"statigo/framework/security"
+)
+
+// ThreatMiddleware scores suspicious requests per client IP: attack tool
+// user agents, path traversal attempts, 404 bursts and rate-limit
+// violations. Clients above a tarpit level are stalled before being served;
+// bans are applied by the scorer once a ban level is reached.
+func ThreatMiddleware(scorer *security.ThreatScorer, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Internal re-renders are never scored
+			if cache.IsRevalidation(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			clientIP := security.GetClientIP(r)
+			record := func(signal string) {
+				scorer.Record(security.ThreatEvent{
+					IP:        clientIP,
+					Signal:    signal,
+					Path:      r.URL.Path,
+					UserAgent: r.UserAgent(),
+				})
+			}
+
+			if scorer.IsSuspiciousAgent(r.UserAgent()) {
+				record(security.SignalSuspiciousAgent)
+			}
+			if scorer.HasTraversal(r.RequestURI) {
+				record(security.SignalPathTraversal)
+			}
+
+			// Stall clients above a tarpit level
+			if delay := scorer.TarpitDelay(clientIP); delay > 0 {
+				logger.Debug("Tarpitting request",
+					"ip", clientIP,
+					"path", r.URL.Path,
+					"delay", delay,
+				)
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
+					return
+				}
+			}
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(wrapped, r)
+
+			switch wrapped.statusCode {
+			case http.StatusNotFound:
+				record(security.SignalNotFound)
+			case http.StatusTooManyRequests:
+				record(security.SignalRateLimited)
+			}
+		})
+	}
+}
@@ -1,46 +1,224 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"statigo/framework/security"
+)
+
+// Webhook signature headers.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // "sha256=" + hex HMAC-SHA256 of the body
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"  // Unique ID of a delivery, reused on retries
 )
 
-// WebhookAuth validates webhook requests using X-Webhook-Secret header.
-func WebhookAuth(webhookSecret string, logger *slog.Logger) func(http.Handler) http.Handler {
+// ErrNoWebhookSecret is returned when webhook auth is configured without a secret.
+var ErrNoWebhookSecret = errors.New("webhook secret is not configured")
+
+// WebhookAuthConfig configures webhook signature verification.
+type WebhookAuthConfig struct {
+	Secret       string        // Shared HMAC key
+	Tolerance    time.Duration // Maximum age (and clock skew) of the payload timestamp
+	MaxBodyBytes int64         // Larger bodies are rejected before verification
+}
+
+// DefaultWebhookAuthConfig returns sensible defaults for the given secret.
+func DefaultWebhookAuthConfig(secret string) WebhookAuthConfig {
+	return WebhookAuthConfig{
+		Secret:       secret,
+		Tolerance:    5 * time.Minute,
+		MaxBodyBytes: 1 << 20, // 1 MiB
+	}
+}
+
+// replayCache remembers delivery IDs and signatures until their timestamps
+// fall outside the tolerance window, after which the freshness check
+// rejects them anyway.
+type replayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // Key -> expiry
+	lastPrune time.Time
+	ttl       time.Duration
+}
+
+// add records the keys and reports false if any of them was already seen.
+func (rc *replayCache) add(now time.Time, keys ...string) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if now.Sub(rc.lastPrune) >= rc.ttl {
+		for key, expiry := range rc.seen {
+			if now.After(expiry) {
+				delete(rc.seen, key)
+			}
+		}
+		rc.lastPrune = now
+	}
+
+	for _, key := range keys {
+		if expiry, ok := rc.seen[key]; ok && now.Before(expiry) {
+			return false
+		}
+	}
+	for _, key := range keys {
+		rc.seen[key] = now.Add(rc.ttl)
+	}
+	return true
+}
+
+// remove forgets keys recorded by add, so a retry of the delivery is accepted.
+func (rc *replayCache) remove(keys ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for _, key := range keys {
+		delete(rc.seen, key)
+	}
+}
+
+// WebhookAuth validates webhook requests signed with HMAC-SHA256. The
+// signature covers the raw body, whose "timestamp" field must be within the
+// tolerance window; delivery IDs and signatures are remembered to reject
+// replays. Deliveries the handler does not accept with a 2xx are forgotten
+// again, so the sender can retry them. It returns ErrNoWebhookSecret when no
+// secret is configured.
+func WebhookAuth(config WebhookAuthConfig, logger *slog.Logger) (func(http.Handler) http.Handler, error) {
+	if config.Secret == "" {
+		return nil, ErrNoWebhookSecret
+	}
+	defaults := DefaultWebhookAuthConfig(config.Secret)
+	if config.Tolerance <= 0 {
+		config.Tolerance = defaults.Tolerance
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+
+	key := []byte(config.Secret)
+	replays := &replayCache{
+		seen: make(map[string]time.Time),
+		// Timestamps are accepted up to Tolerance in either direction
+		ttl: 2 * config.Tolerance,
+	}
+
+	reject := func(w http.ResponseWriter, r *http.Request, status int, reason, message string) {
+		logger.Warn("webhook auth failed - "+reason,
+			slog.String("ip", security.GetClientIP(r)),
+			slog.String("path", r.URL.Path),
+			slog.String("delivery", r.Header.Get(WebhookDeliveryHeader)),
+		)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "message": message})
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the secret from header
-			providedSecret := r.Header.Get("X-Webhook-Secret")
+			signature, ok := strings.CutPrefix(r.Header.Get(WebhookSignatureHeader), "sha256=")
+			if !ok || signature == "" {
+				reject(w, r, http.StatusUnauthorized, "missing signature", "Missing "+WebhookSignatureHeader+" header")
+				return
+			}
+			provided, err := hex.DecodeString(signature)
+			if err != nil {
+				reject(w, r, http.StatusUnauthorized, "malformed signature", "Invalid "+WebhookSignatureHeader)
+				return
+			}
 
-			// Validate secret
-			if providedSecret == "" {
-				logger.Warn("webhook auth failed - missing X-Webhook-Secret header",
-					slog.String("remote_addr", r.RemoteAddr),
-					slog.String("path", r.URL.Path),
-				)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"success":false,"message":"Missing X-Webhook-Secret header"}`))
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, config.MaxBodyBytes))
+			if err != nil {
+				reject(w, r, http.StatusRequestEntityTooLarge, "unreadable body", "Request body too large")
 				return
 			}
 
-			if providedSecret != webhookSecret {
-				logger.Warn("webhook auth failed - invalid X-Webhook-Secret",
-					slog.String("remote_addr", r.RemoteAddr),
-					slog.String("path", r.URL.Path),
-				)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"success":false,"message":"Invalid X-Webhook-Secret"}`))
+			// Constant-time comparison of the body signature
+			mac := hmac.New(sha256.New, key)
+			mac.Write(body)
+			if !hmac.Equal(provided, mac.Sum(nil)) {
+				reject(w, r, http.StatusUnauthorized, "invalid signature", "Invalid "+WebhookSignatureHeader)
+				return
+			}
+
+			// The timestamp is part of the signed body, so it can't be altered
+			var envelope struct {
+				Timestamp string `json:"timestamp"`
+			}
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				reject(w, r, http.StatusBadRequest, "invalid payload", "Invalid payload")
+				return
+			}
+			sent, err := time.Parse(time.RFC3339, envelope.Timestamp)
+			if err != nil {
+				reject(w, r, http.StatusUnauthorized, "missing timestamp", "Missing or invalid payload timestamp")
+				return
+			}
+			now := time.Now()
+			if age := now.Sub(sent); age > config.Tolerance || age < -config.Tolerance {
+				reject(w, r, http.StatusUnauthorized, "stale timestamp", "Payload timestamp outside the accepted window")
 				return
 			}
 
-			// Secret is valid, proceed
+			keys := []string{"sig:" + strings.ToLower(signature)}
+			if delivery := r.Header.Get(WebhookDeliveryHeader); delivery != "" {
+				keys = append(keys, "id:"+delivery)
+			}
+			if !replays.add(now, keys...) {
+				reject(w, r, http.StatusConflict, "replayed delivery", "Delivery already processed")
+				return
+			}
+
+			// Signature is valid, hand the body on
 			logger.Debug("webhook authenticated",
-				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("ip", security.GetClientIP(r)),
 				slog.String("path", r.URL.Path),
 			)
-			next.ServeHTTP(w, r)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+
+			// Let retries of a failed delivery through
+			if wrapped.statusCode < 200 || wrapped.statusCode >= 300 {
+				replays.remove(keys...)
+			}
 		})
+	}, nil
+}
+
+// TokenAuth validates requests carrying "Authorization: Bearer <token>",
+// compared in constant time. It returns an error when no token is configured.
+func TokenAuth(token string, logger *slog.Logger) (func(http.Handler) http.Handler, error) {
+	if token == "" {
+		return nil, errors.New("auth token is not configured")
 	}
+	expected := sha256.Sum256([]byte(token))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			// Hash both sides so the comparison doesn't leak the token length
+			sum := sha256.Sum256([]byte(provided))
+			if !ok || !hmac.Equal(sum[:], expected[:]) {
+				logger.Warn("token auth failed",
+					slog.String("ip", security.GetClientIP(r)),
+					slog.String("path", r.URL.Path),
+				)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]any{"success": false, "message": "Invalid or missing bearer token"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
 }
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
 	fwctx "statigo/framework/context"
 	"statigo/framework/templates"
@@ -14,13 +15,15 @@ import (
 
 // RouteConfig represents a single route configuration from JSON.
 type RouteConfig struct {
-	Canonical string `json:"canonical"`
-	Path      string `json:"path"`
-	Template  string `json:"template"`
-	Handler   string `json:"handler"`  // Handler name (e.g., "index", "content")
-	Title     string `json:"title"`    // Translation key for page title
-	Strategy  string `json:"strategy"` // Caching strategy: "static", "incremental", "dynamic", "immutable"
-	Interval  string `json:"interval"` // Revalidation interval for incremental strategy (e.g., "24h")
+	Canonical string           `json:"canonical"`
+	Path      string           `json:"path"`
+	Template  string           `json:"template"`
+	Handler   string           `json:"handler"`                   // Handler name (e.g., "index", "content")
+	Title     string           `json:"title"`                     // Translation key for page title
+	Strategy  string           `json:"strategy"`                  // Caching strategy: "static", "incremental", "dynamic", "immutable"
+	Interval  string           `json:"interval"`                  // Revalidation interval for incremental strategy (e.g., "24h")
+	RateLimit *RateLimit       `json:"rateLimit,omitempty"`       // Per-route rate limit override
+	Headers   *SecurityHeaders `json:"securityHeaders,omitempty"` // Per-route security header overrides
 }
 
 // RoutesConfig represents the complete routes configuration file.
@@ -53,12 +56,30 @@ func LoadRoutesFromJSON(
 
 	// Register each static route
 	for _, routeConfig := range config.Routes {
+		if routeConfig.Interval != "" {
+			if _, err := time.ParseDuration(routeConfig.Interval); err != nil {
+				return fmt.Errorf("invalid interval %q for route %s: %w", routeConfig.Interval, routeConfig.Path, err)
+			}
+		}
+
+		if limit := routeConfig.RateLimit; limit != nil {
+			if limit.RPS <= 0 {
+				return fmt.Errorf("invalid rate limit for route %s: rps must be positive", routeConfig.Path)
+			}
+			if limit.Burst <= 0 {
+				limit.Burst = limit.RPS
+			}
+		}
+
 		// Strategy-only entries (no handler) are stored in the registry for
 		// wildcard pattern lookups but are not registered as chi routes.
 		if routeConfig.Handler == "" {
 			if err := registry.AddRoute(RouteDefinition{
-				Path:     routeConfig.Path,
-				Strategy: routeConfig.Strategy,
+				Path:      routeConfig.Path,
+				Strategy:  routeConfig.Strategy,
+				Interval:  routeConfig.Interval,
+				RateLimit: routeConfig.RateLimit,
+				Headers:   routeConfig.Headers,
 			}); err != nil {
 				return fmt.Errorf("failed to add route %s: %w", routeConfig.Path, err)
 			}
@@ -141,6 +162,8 @@ func LoadRoutesFromJSON(
 			Title:     routeConfig.Title,
 			Strategy:  routeConfig.Strategy,
 			Interval:  routeConfig.Interval,
+			RateLimit: routeConfig.RateLimit,
+			Headers:   routeConfig.Headers,
 		}); err != nil {
 			return fmt.Errorf("failed to add route %s: %w", routeConfig.Canonical, err)
 		}
@@ -3,12 +3,13 @@ package router
 import (
 	"context"
 	"net/http"
+	"time"
 
 	fwctx "statigo/framework/context"
 )
 
 // CanonicalPathMiddleware creates middleware that stores canonical path,
-// page title, and cache strategy in the request context.
+// page title, cache strategy and revalidation interval in the request context.
 func CanonicalPathMiddleware(registry *Registry) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +24,9 @@ func CanonicalPathMiddleware(registry *Registry) func(http.Handler) http.Handler
 				if route.Strategy != "" {
 					ctx = fwctx.SetStrategy(ctx, route.Strategy)
 				}
+				if interval := route.IntervalDuration(); interval > 0 {
+					ctx = fwctx.SetInterval(ctx, interval)
+				}
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -33,6 +37,9 @@ func CanonicalPathMiddleware(registry *Registry) func(http.Handler) http.Handler
 				if route.Strategy != "" {
 					ctx = fwctx.SetStrategy(ctx, route.Strategy)
 				}
+				if interval := route.IntervalDuration(); interval > 0 {
+					ctx = fwctx.SetInterval(ctx, interval)
+				}
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -57,3 +64,8 @@ func GetPageTitle(ctx context.Context) string {
 func GetStrategy(ctx context.Context) string {
 	return fwctx.GetStrategy(ctx)
 }
+
+// GetInterval retrieves the cache revalidation interval from context.
+func GetInterval(ctx context.Context) time.Duration {
+	return fwctx.GetInterval(ctx)
+}
@@ -4,6 +4,7 @@ package router
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 )
@@ -17,6 +18,39 @@ type RouteDefinition struct {
 	Title     string           // Translation key for page title (e.g., "main.title")
 	Strategy  string           // Caching strategy: "static", "incremental", "dynamic", "immutable"
 	Interval  string           // Revalidation interval for incremental strategy (e.g., "24h")
+	RateLimit *RateLimit       // Per-route rate limit override (nil uses the global limit)
+	Headers   *SecurityHeaders // Per-route security header overrides (nil uses the global headers)
+}
+
+// RateLimit overrides the global rate limit for a route.
+type RateLimit struct {
+	RPS   int `json:"rps"`   // Requests per second per client
+	Burst int `json:"burst"` // Maximum burst size per client
+}
+
+// SecurityHeaders overrides the global security headers for a route. Omitted
+// fields keep the global value and empty strings remove the header.
+type SecurityHeaders struct {
+	FrameOptions              *string  `json:"frameOptions,omitempty"`
+	FrameAncestors            []string `json:"frameAncestors,omitempty"` // CSP frame-ancestors sources
+	ReferrerPolicy            *string  `json:"referrerPolicy,omitempty"`
+	PermissionsPolicy         *string  `json:"permissionsPolicy,omitempty"`
+	CrossOriginOpenerPolicy   *string  `json:"crossOriginOpenerPolicy,omitempty"`
+	CrossOriginEmbedderPolicy *string  `json:"crossOriginEmbedderPolicy,omitempty"`
+	CrossOriginResourcePolicy *string  `json:"crossOriginResourcePolicy,omitempty"`
+}
+
+// IntervalDuration returns the parsed revalidation interval, or zero when
+// the route has no interval or it cannot be parsed.
+func (d *RouteDefinition) IntervalDuration() time.Duration {
+	if d.Interval == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(d.Interval)
+	if err != nil {
+		return 0
+	}
+	return interval
 }
 
 // Registry maintains the mapping between canonical paths and route definitions.
@@ -89,6 +123,15 @@ func (r *Registry) GetByPathPattern(path string) *RouteDefinition {
 	return nil
 }
 
+// Lookup returns the route definition for a path, trying exact paths before
+// pattern routes. Returns nil if no route matches.
+func (r *Registry) Lookup(path string) *RouteDefinition {
+	if route := r.GetByPath(path); route != nil {
+		return route
+	}
+	return r.GetByPathPattern(path)
+}
+
 // GetAll returns all registered routes.
 func (r *Registry) GetAll() []RouteDefinition {
 	return r.routes
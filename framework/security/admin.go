@@ -0,0 +1,293 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// BanAdmin is the set of ban list operations exposed to admin tools. It is
+// implemented in-process by LocalBanAdmin and remotely by AdminClient.
+type BanAdmin interface {
+	List() ([]*BanEntry, error)
+	Ban(entry BanEntry) error
+	Unban(target string) error
+	Import(entries []*BanEntry) (int, error)
+	Prune() (int, error)
+}
+
+// LocalBanAdmin adapts an IPBanList to the BanAdmin interface.
+type LocalBanAdmin struct {
+	BanList *IPBanList
+}
+
+// List returns all active bans.
+func (a LocalBanAdmin) List() ([]*BanEntry, error) { return a.BanList.GetAll(), nil }
+
+// Ban adds a ban.
+func (a LocalBanAdmin) Ban(entry BanEntry) error { return a.BanList.Ban(entry) }
+
+// Unban removes a ban.
+func (a LocalBanAdmin) Unban(target string) error { return a.BanList.UnbanIP(target) }
+
+// Import adds several bans at once.
+func (a LocalBanAdmin) Import(entries []*BanEntry) (int, error) { return a.BanList.Import(entries) }
+
+// Prune removes expired bans.
+func (a LocalBanAdmin) Prune() (int, error) { return a.BanList.Prune() }
+
+// AdminServer exposes a ban list, and any endpoints added with Handle, over
+// a local unix socket so admin commands can modify the running server's
+// state instead of racing it on disk. The caller holds the ban list lock
+// (see LockFile) for as long as the ban list is in use.
+type AdminServer struct {
+	socketPath string
+	admin      BanAdmin
+	logger     *slog.Logger
+	mux        *http.ServeMux
+	listener   net.Listener
+	server     *http.Server
+}
+
+// NewAdminServer creates an admin server for the given ban list.
+func NewAdminServer(socketPath string, banList *IPBanList, logger *slog.Logger) *AdminServer {
+	s := &AdminServer{
+		socketPath: socketPath,
+		admin:      LocalBanAdmin{BanList: banList},
+		logger:     logger,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /bans", s.handleList)
+	s.mux.HandleFunc("POST /bans", s.handleBan)
+	s.mux.HandleFunc("DELETE /bans", s.handleUnban)
+	s.mux.HandleFunc("POST /bans/import", s.handleImport)
+	s.mux.HandleFunc("POST /bans/prune", s.handlePrune)
+	return s
+}
+
+// Handle registers an additional admin endpoint. It must be called before Start.
+func (s *AdminServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start begins serving on the socket.
+func (s *AdminServer) Start() error {
+	// Remove a socket left behind by a previous run
+	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale admin socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	if err := os.Chmod(s.socketPath, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict admin socket: %w", err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Admin socket stopped", "error", err)
+		}
+	}()
+
+	s.logger.Info("Admin socket listening", "socket", s.socketPath)
+	return nil
+}
+
+// Close stops the server and removes the socket.
+func (s *AdminServer) Close() error {
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Close()
+	os.Remove(s.socketPath)
+	return err
+}
+
+func (s *AdminServer) handleList(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.admin.List()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAdminJSON(w, entries)
+}
+
+func (s *AdminServer) handleBan(w http.ResponseWriter, r *http.Request) {
+	var entry BanEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.admin.Ban(entry); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAllowlisted) {
+			status = http.StatusConflict
+		}
+		writeAdminError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *AdminServer) handleUnban(w http.ResponseWriter, r *http.Request) {
+	if err := s.admin.Unban(r.URL.Query().Get("ip")); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *AdminServer) handleImport(w http.ResponseWriter, r *http.Request) {
+	var entries []*BanEntry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	imported, err := s.admin.Import(entries)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAdminJSON(w, map[string]int{"count": imported})
+}
+
+func (s *AdminServer) handlePrune(w http.ResponseWriter, r *http.Request) {
+	pruned, err := s.admin.Prune()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAdminJSON(w, map[string]int{"count": pruned})
+}
+
+// writeAdminJSON writes a JSON response.
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAdminError writes a JSON error response.
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// AdminClient talks to a running server's admin socket.
+type AdminClient struct {
+	client *http.Client
+}
+
+// DialAdmin connects to the admin socket. It returns an error if no server
+// is listening.
+func DialAdmin(socketPath string) (*AdminClient, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return &AdminClient{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}, nil
+}
+
+// List returns all active bans.
+func (c *AdminClient) List() ([]*BanEntry, error) {
+	var entries []*BanEntry
+	err := c.Call(http.MethodGet, "/bans", nil, &entries)
+	return entries, err
+}
+
+// Ban adds a ban.
+func (c *AdminClient) Ban(entry BanEntry) error {
+	return c.Call(http.MethodPost, "/bans", entry, nil)
+}
+
+// Unban removes a ban.
+func (c *AdminClient) Unban(target string) error {
+	return c.Call(http.MethodDelete, "/bans?ip="+url.QueryEscape(target), nil, nil)
+}
+
+// Import adds several bans at once.
+func (c *AdminClient) Import(entries []*BanEntry) (int, error) {
+	var result map[string]int
+	err := c.Call(http.MethodPost, "/bans/import", entries, &result)
+	return result["count"], err
+}
+
+// Prune removes expired bans.
+func (c *AdminClient) Prune() (int, error) {
+	var result map[string]int
+	err := c.Call(http.MethodPost, "/bans/prune", nil, &result)
+	return result["count"], err
+}
+
+// Call sends a request to the admin socket and decodes the JSON response into out.
+func (c *AdminClient) Call(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://admin"+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if resp.StatusCode == http.StatusConflict {
+			return fmt.Errorf("%s: %w", strings.TrimSuffix(apiErr.Error, ": "+ErrAllowlisted.Error()), ErrAllowlisted)
+		}
+		return fmt.Errorf("admin request failed with status %d: %s", resp.StatusCode, apiErr.Error)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	fwctx "statigo/framework/context"
+)
+
+// ClientIPConfig configures how the client IP is resolved behind proxies.
+type ClientIPConfig struct {
+	TrustedProxies []string // CIDRs or single IPs of proxies allowed to set forwarding headers
+	UseForwarded   bool     // Prefer the RFC 7239 Forwarded header over X-Forwarded-For
+}
+
+// ClientIPResolver determines the real client IP of a request. Forwarding
+// headers are only honored when the connection comes from a trusted proxy,
+// and X-Forwarded-For is walked from the right so clients can't spoof the
+// address by prepending entries.
+type ClientIPResolver struct {
+	trusted      []netip.Prefix
+	useForwarded bool
+}
+
+// NewClientIPResolver creates a resolver from the given configuration.
+func NewClientIPResolver(config ClientIPConfig) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{useForwarded: config.UseForwarded}
+
+	for _, proxy := range config.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			resolver.trusted = append(resolver.trusted, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		addr = addr.Unmap()
+		resolver.trusted = append(resolver.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return resolver, nil
+}
+
+// Resolve returns the client IP for a request.
+func (cr *ClientIPResolver) Resolve(r *http.Request) string {
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return remoteHost(r.RemoteAddr)
+	}
+	if !cr.isTrusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	if cr.useForwarded {
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	}
+	if len(hops) == 0 {
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	}
+
+	if len(hops) == 0 {
+		// Single proxy setups often only send X-Real-IP
+		if realIP, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+		return remote.String()
+	}
+
+	// Walk from the right: the first address not added by a trusted proxy
+	// is the client. Stop at anything unparsable and keep the last good hop.
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !cr.isTrusted(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// Scheme returns the scheme the client used, "http" or "https". Behind a
+// trusted proxy it is taken from the Forwarded proto parameter (when
+// enabled) or X-Forwarded-Proto.
+func (cr *ClientIPResolver) Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok || !cr.isTrusted(remote) {
+		return "http"
+	}
+
+	var proto string
+	if cr.useForwarded {
+		proto = forwardedProto(r.Header.Values("Forwarded"))
+	}
+	if proto == "" {
+		// The nearest proxy's value is the last one
+		values := strings.Split(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+		proto = strings.TrimSpace(values[len(values)-1])
+	}
+	if strings.EqualFold(proto, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy.
+func (cr *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range cr.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor extracts the "for" parameters from Forwarded header values
+// in order, e.g. `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedProto returns the last "proto" parameter of the Forwarded header.
+func forwardedProto(values []string) string {
+	var proto string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "proto") {
+					proto = strings.Trim(val, `"`)
+				}
+			}
+		}
+	}
+	return proto
+}
+
+// parseAddr parses an IP address with an optional port or IPv6 brackets.
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		if addr, err := netip.ParseAddr(value[1 : len(value)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// remoteHost strips the port from a remote address.
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// GetClientIP returns the client IP resolved by the client IP middleware.
+// Without it, forwarding headers are ignored and the connection's remote
+// address is used.
+func GetClientIP(r *http.Request) string {
+	if ip := fwctx.GetClientIP(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// IsHTTPS reports whether the client connected over HTTPS, as resolved by
+// the client IP middleware. Without it, only the connection itself is checked.
+func IsHTTPS(r *http.Request) bool {
+	if scheme := fwctx.GetScheme(r.Context()); scheme != "" {
+		return scheme == "https"
+	}
+	return r.TLS != nil
+}
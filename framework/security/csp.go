@@ -0,0 +1,171 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// Common CSP source expressions.
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPUnsafeHashes  = "'unsafe-hashes'" // Lets hashes match inline event handlers
+	CSPStrictDynamic = "'strict-dynamic'"
+	CSPData          = "data:"
+	CSPHTTPS         = "https:"
+)
+
+// CSPReportGroup is the Reporting API endpoint name used by report-to.
+const CSPReportGroup = "csp"
+
+// CSPPlaceholderHeader names the nonce placeholder of an HTML response. The
+// CSP middleware replaces that placeholder with the request's nonce and
+// removes the header, so cached pages get a fresh nonce on every request.
+const CSPPlaceholderHeader = "X-Csp-Nonce-Placeholder"
+
+// cspNonceMarker is rendered by templates wherever a page needs the CSP
+// nonce. It is random per process and never sent to clients: each rendered
+// page gets its own random placeholder instead, so content injected into a
+// page cannot predict the value that is swapped for the nonce.
+var cspNonceMarker = NewCSPNonce()
+
+// CSPConfig describes a Content-Security-Policy. Each source list maps to
+// the directive of the same name; empty lists are left out of the policy.
+type CSPConfig struct {
+	DefaultSrc     []string
+	ScriptSrc      []string
+	StyleSrc       []string
+	StyleSrcAttr   []string // Inline style="" attributes
+	ImgSrc         []string
+	FontSrc        []string
+	ConnectSrc     []string
+	FrameSrc       []string
+	MediaSrc       []string
+	ObjectSrc      []string
+	ManifestSrc    []string
+	WorkerSrc      []string
+	BaseURI        []string
+	FormAction     []string
+	FrameAncestors []string
+
+	UpgradeInsecureRequests bool
+	Nonce                   bool   // Add a per-request nonce to script-src and style-src
+	ReportOnly              bool   // Report violations without enforcing the policy
+	ReportURI               string // Where browsers send violation reports
+}
+
+// DefaultCSPConfig returns a strict policy allowing only same-origin
+// resources and nonce-tagged inline scripts.
+func DefaultCSPConfig() CSPConfig {
+	return CSPConfig{
+		DefaultSrc:     []string{CSPSelf},
+		ScriptSrc:      []string{CSPSelf},
+		StyleSrc:       []string{CSPSelf},
+		ImgSrc:         []string{CSPSelf, CSPData},
+		FontSrc:        []string{CSPSelf},
+		ConnectSrc:     []string{CSPSelf},
+		ObjectSrc:      []string{CSPNone},
+		BaseURI:        []string{CSPSelf},
+		FormAction:     []string{CSPSelf},
+		FrameAncestors: []string{CSPNone},
+		Nonce:          true,
+	}
+}
+
+// HeaderName returns the header the policy is sent in.
+func (c CSPConfig) HeaderName() string {
+	if c.ReportOnly {
+		return "Content-Security-Policy-Report-Only"
+	}
+	return "Content-Security-Policy"
+}
+
+// Build renders the policy. A non-empty nonce is added to script-src and
+// style-src when Nonce is set.
+func (c CSPConfig) Build(nonce string) string {
+	var nonceSource string
+	if c.Nonce && nonce != "" {
+		nonceSource = "'nonce-" + nonce + "'"
+	}
+
+	var b strings.Builder
+	directive := func(name string, sources []string, extra string) {
+		if len(sources) == 0 && extra == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(name)
+		for _, source := range sources {
+			b.WriteByte(' ')
+			b.WriteString(source)
+		}
+		if extra != "" {
+			b.WriteByte(' ')
+			b.WriteString(extra)
+		}
+	}
+
+	directive("default-src", c.DefaultSrc, "")
+	directive("script-src", c.ScriptSrc, nonceSource)
+	directive("style-src", c.StyleSrc, nonceSource)
+	directive("style-src-attr", c.StyleSrcAttr, "")
+	directive("img-src", c.ImgSrc, "")
+	directive("font-src", c.FontSrc, "")
+	directive("connect-src", c.ConnectSrc, "")
+	directive("frame-src", c.FrameSrc, "")
+	directive("media-src", c.MediaSrc, "")
+	directive("object-src", c.ObjectSrc, "")
+	directive("manifest-src", c.ManifestSrc, "")
+	directive("worker-src", c.WorkerSrc, "")
+	directive("base-uri", c.BaseURI, "")
+	directive("form-action", c.FormAction, "")
+	directive("frame-ancestors", c.FrameAncestors, "")
+	// Browsers ignore upgrade-insecure-requests in report-only policies
+	if c.UpgradeInsecureRequests && !c.ReportOnly {
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString("upgrade-insecure-requests")
+	}
+	if c.ReportURI != "" {
+		directive("report-uri", []string{c.ReportURI}, "")
+		directive("report-to", []string{CSPReportGroup}, "")
+	}
+	return b.String()
+}
+
+// NewCSPNonce returns a random nonce safe to use in unquoted HTML attributes.
+func NewCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CSPNonceMarker returns the value templates render in nonce attributes.
+func CSPNonceMarker() string {
+	return cspNonceMarker
+}
+
+// ReplaceCSPNonceMarker replaces the nonce marker in a rendered page with a
+// new random placeholder. It returns the page and the placeholder, which is
+// empty if the page needs no nonce. Marker, placeholder and nonce have the
+// same length, which keeps Content-Length valid.
+func ReplaceCSPNonceMarker(page string) (string, string) {
+	if !strings.Contains(page, cspNonceMarker) {
+		return page, ""
+	}
+	placeholder := NewCSPNonce()
+	return strings.ReplaceAll(page, cspNonceMarker, placeholder), placeholder
+}
+
+// CSPHash returns the hash source expression for an inline script, style or
+// (with CSPUnsafeHashes) event handler.
+func CSPHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
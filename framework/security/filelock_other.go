@@ -0,0 +1,13 @@
+//go:build !unix
+
+package security
+
+import "errors"
+
+// ErrLocked is returned when another process holds the ban list lock.
+var ErrLocked = errors.New("ban list is locked by another process")
+
+// LockFile is a no-op on platforms without flock.
+func LockFile(path string) (func(), error) {
+	return func() {}, nil
+}
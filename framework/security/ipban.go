@@ -2,191 +2,421 @@
 package security
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net"
-	"net/http"
-	"os"
+	"net/netip"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-// BanEntry represents a banned IP with metadata.
+// ErrAllowlisted is returned when trying to ban an allowlisted address.
+var ErrAllowlisted = errors.New("address is allowlisted")
+
+// pruneInterval is how often expired bans are removed.
+const pruneInterval = time.Minute
+
+// BanEntry represents a banned IP or CIDR range with metadata.
 type BanEntry struct {
-	IP        string    `json:"ip"`
+	IP        string    `json:"ip"` // Single IP ("203.0.113.7") or CIDR range ("2001:db8::/64")
 	Reason    string    `json:"reason"`
 	BannedAt  time.Time `json:"bannedAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"` // Zero means the ban never expires
 	UserAgent string    `json:"userAgent,omitempty"`
 	Path      string    `json:"path,omitempty"`
 }
 
-// IPBanList manages a list of banned IP addresses.
+// IsExpired reports whether the ban has expired at the given time.
+func (e *BanEntry) IsExpired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// IPBanList manages a list of banned IP addresses and ranges.
+// Single IPs are stored as full-length prefixes, so a lookup costs one map
+// access per distinct prefix length in use.
 type IPBanList struct {
-	mu       sync.RWMutex
-	banned   map[string]*BanEntry
-	filePath string
-	logger   *slog.Logger
+	mu         sync.RWMutex
+	banned     map[netip.Prefix]*BanEntry
+	prefixLens map[int]int // Prefix length -> number of bans using it (IPv4 lengths offset by ipv4LenOffset)
+	allowlist  []netip.Prefix
+	filePath   string
+	logger     *slog.Logger
+	pruneTimer *time.Timer
+	stopped    bool
+
+	// Persistence state, see persist.go
+	persistMu      sync.Mutex      // Serializes writes to the snapshot and journal
+	pending        []journalRecord // Changes not yet written to the journal
+	flushTimer     *time.Timer
+	journalRecords int // Records in the journal since the last compaction
+	lastCompaction time.Time
 }
 
+// ipv4LenOffset keeps IPv4 and IPv6 prefix lengths apart in prefixLens.
+const ipv4LenOffset = 1000
+
 // NewIPBanList creates a new IP ban list manager.
 func NewIPBanList(filePath string, logger *slog.Logger) (*IPBanList, error) {
 	banList := &IPBanList{
-		banned:   make(map[string]*BanEntry),
-		filePath: filePath,
-		logger:   logger,
+		banned:     make(map[netip.Prefix]*BanEntry),
+		prefixLens: make(map[int]int),
+		filePath:   filePath,
+		logger:     logger,
 	}
 
 	// Load existing ban list from file
 	if err := banList.load(); err != nil {
-		logger.Warn("Failed to load existing ban list, starting with empty list", "error", err)
+		logger.Error("Failed to load existing ban list, starting with empty list", "error", err)
 	}
+	banList.lastCompaction = time.Now()
+
+	// Start periodic removal of expired bans
+	banList.startPruning()
 
 	return banList, nil
 }
 
-// BanIP adds an IP address to the ban list.
-func (bl *IPBanList) BanIP(ip, reason, userAgent, path string) error {
+// ParseBanTarget parses a single IP or CIDR range into a normalized prefix.
+func ParseBanTarget(target string) (netip.Prefix, error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: %w", target, err)
+		}
+		if prefix.Addr().Is4In6() {
+			bits := prefix.Bits() - 96
+			if bits < 0 {
+				return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: prefix too short for IPv4-mapped address", target)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q: %w", target, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// formatBanTarget returns the canonical string for a ban prefix: a bare IP
+// for single addresses, CIDR notation for ranges.
+func formatBanTarget(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// SetAllowlist sets the IPs and CIDR ranges that can never be banned.
+func (bl *IPBanList) SetAllowlist(targets []string) error {
+	var allowlist []netip.Prefix
+	for _, target := range targets {
+		if strings.TrimSpace(target) == "" {
+			continue
+		}
+		prefix, err := ParseBanTarget(target)
+		if err != nil {
+			return fmt.Errorf("invalid allowlist entry: %w", err)
+		}
+		allowlist = append(allowlist, prefix)
+	}
+
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
-	entry := &BanEntry{
+	bl.allowlist = allowlist
+	return nil
+}
+
+// IsAllowlisted reports whether an IP address can never be banned.
+func (bl *IPBanList) IsAllowlisted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+
+	return bl.isAllowlisted(netip.PrefixFrom(addr, addr.BitLen()))
+}
+
+// isAllowlisted reports whether any part of prefix is allowlisted.
+// Callers must hold the lock.
+func (bl *IPBanList) isAllowlisted(prefix netip.Prefix) bool {
+	for _, allowed := range bl.allowlist {
+		if allowed.Overlaps(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// BanIP permanently bans an IP address or CIDR range.
+func (bl *IPBanList) BanIP(ip, reason, userAgent, path string) error {
+	return bl.Ban(BanEntry{
 		IP:        ip,
 		Reason:    reason,
-		BannedAt:  time.Now(),
 		UserAgent: userAgent,
 		Path:      path,
+	})
+}
+
+// Ban adds an entry to the ban list, replacing any existing ban for the same
+// IP or range. Set ExpiresAt for a temporary ban.
+func (bl *IPBanList) Ban(entry BanEntry) error {
+	prefix, err := ParseBanTarget(entry.IP)
+	if err != nil {
+		return err
+	}
+	entry.IP = formatBanTarget(prefix)
+	if entry.BannedAt.IsZero() {
+		entry.BannedAt = time.Now()
 	}
 
-	bl.banned[ip] = entry
-	bl.logger.Warn("IP banned",
-		"ip", ip,
-		"reason", reason,
-		"path", path,
-		"user_agent", userAgent,
-	)
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
 
-	// Save to file
-	return bl.save()
-}
+	if bl.isAllowlisted(prefix) {
+		return fmt.Errorf("failed to ban %s: %w", entry.IP, ErrAllowlisted)
+	}
 
-// IsBanned checks if an IP address is banned.
-func (bl *IPBanList) IsBanned(ip string) bool {
-	bl.mu.RLock()
-	defer bl.mu.RUnlock()
+	bl.add(prefix, &entry)
+	bl.record(journalRecord{Op: journalBan, Entry: &entry})
+	bl.logger.Warn("IP banned",
+		"ip", entry.IP,
+		"reason", entry.Reason,
+		"path", entry.Path,
+		"user_agent", entry.UserAgent,
+		"expires_at", entry.ExpiresAt,
+	)
 
-	_, banned := bl.banned[ip]
-	return banned
+	return nil
 }
 
-// UnbanIP removes an IP address from the ban list.
-func (bl *IPBanList) UnbanIP(ip string) error {
+// Import adds several entries and persists them once. Invalid, expired and
+// allowlisted entries are skipped. It returns the number of entries added.
+func (bl *IPBanList) Import(entries []*BanEntry) (int, error) {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
 
-	delete(bl.banned, ip)
-	bl.logger.Info("IP unbanned", "ip", ip)
+	now := time.Now()
+	imported := 0
+	for _, entry := range entries {
+		if entry == nil || entry.IsExpired(now) {
+			continue
+		}
+		prefix, err := ParseBanTarget(entry.IP)
+		if err != nil {
+			bl.logger.Warn("Skipping invalid ban entry", "ip", entry.IP, "error", err)
+			continue
+		}
+		if bl.isAllowlisted(prefix) {
+			bl.logger.Info("Skipping allowlisted ban entry", "ip", entry.IP)
+			continue
+		}
+
+		added := *entry
+		added.IP = formatBanTarget(prefix)
+		if added.BannedAt.IsZero() {
+			added.BannedAt = now
+		}
+		bl.add(prefix, &added)
+		bl.record(journalRecord{Op: journalBan, Entry: &added})
+		imported++
+	}
 
-	return bl.save()
+	if imported > 0 {
+		bl.logger.Info("Imported bans", "count", imported)
+	}
+	return imported, nil
 }
 
-// Count returns the number of banned IPs.
-func (bl *IPBanList) Count() int {
+// IsBanned checks if an IP address is covered by an active ban.
+func (bl *IPBanList) IsBanned(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
 	bl.mu.RLock()
 	defer bl.mu.RUnlock()
 
-	return len(bl.banned)
+	if bl.lookup(addr, time.Now()) == nil {
+		return false
+	}
+	return !bl.isAllowlisted(netip.PrefixFrom(addr, addr.BitLen()))
 }
 
-// GetAll returns all banned IP entries.
-func (bl *IPBanList) GetAll() []*BanEntry {
-	bl.mu.RLock()
-	defer bl.mu.RUnlock()
+// lookup returns an active ban covering addr, checking each prefix length in
+// use. Expired bans that have not been pruned yet are skipped, so they can't
+// hide an active ban of another length. Callers must hold the lock.
+func (bl *IPBanList) lookup(addr netip.Addr, now time.Time) *BanEntry {
+	offset := 0
+	if addr.Is4() {
+		offset = ipv4LenOffset
+	}
 
-	entries := make([]*BanEntry, 0, len(bl.banned))
-	for _, entry := range bl.banned {
-		entries = append(entries, entry)
+	for key := range bl.prefixLens {
+		bits := key - offset
+		if bits < 0 || bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if entry, exists := bl.banned[prefix]; exists && !entry.IsExpired(now) {
+			return entry
+		}
 	}
-	return entries
+	return nil
 }
 
-// save persists the ban list to disk.
-func (bl *IPBanList) save() error {
-	file, err := os.Create(bl.filePath)
+// UnbanIP removes the ban for an IP address or CIDR range.
+func (bl *IPBanList) UnbanIP(ip string) error {
+	prefix, err := ParseBanTarget(ip)
 	if err != nil {
-		return fmt.Errorf("failed to create ban list file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
 
-	entries := make([]*BanEntry, 0, len(bl.banned))
-	for _, entry := range bl.banned {
-		entries = append(entries, entry)
+	if !bl.remove(prefix) {
+		return nil
 	}
+	bl.record(journalRecord{Op: journalUnban, IP: formatBanTarget(prefix)})
+	bl.logger.Info("IP unbanned", "ip", formatBanTarget(prefix))
+
+	return nil
+}
+
+// Prune removes expired bans and persists the change. It returns the number
+// of bans removed.
+func (bl *IPBanList) Prune() (int, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
 
-	if err := encoder.Encode(entries); err != nil {
-		return fmt.Errorf("failed to encode ban list: %w", err)
+	now := time.Now()
+	removed := 0
+	for prefix, entry := range bl.banned {
+		if entry.IsExpired(now) {
+			bl.remove(prefix)
+			bl.record(journalRecord{Op: journalUnban, IP: entry.IP})
+			removed++
+		}
 	}
 
-	return nil
+	if removed > 0 {
+		bl.logger.Info("Pruned expired bans", "count", removed)
+	}
+	return removed, nil
 }
 
-// load reads the ban list from disk.
-func (bl *IPBanList) load() error {
-	file, err := os.Open(bl.filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File doesn't exist yet, that's fine
+// startPruning begins the periodic removal of expired bans and compaction
+// of the journal.
+func (bl *IPBanList) startPruning() {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	if bl.stopped {
+		return
+	}
+	bl.pruneTimer = time.AfterFunc(pruneInterval, func() {
+		if _, err := bl.Prune(); err != nil {
+			bl.logger.Warn("Failed to prune ban list", "error", err)
+		}
+		if time.Since(bl.lastCompactionTime()) >= compactInterval {
+			if err := bl.Compact(); err != nil {
+				bl.logger.Warn("Failed to compact ban list", "error", err)
+			}
 		}
-		return fmt.Errorf("failed to open ban list file: %w", err)
+		bl.startPruning() // Reschedule
+	})
+}
+
+// Stop stops the background timers and writes pending changes to disk.
+func (bl *IPBanList) Stop() {
+	bl.mu.Lock()
+	bl.stopped = true
+	if bl.pruneTimer != nil {
+		bl.pruneTimer.Stop()
 	}
-	defer file.Close()
+	bl.mu.Unlock()
 
-	var entries []*BanEntry
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&entries); err != nil {
-		return fmt.Errorf("failed to decode ban list: %w", err)
+	if err := bl.Flush(); err != nil {
+		bl.logger.Error("Failed to persist ban list", "error", err)
 	}
+}
 
-	for _, entry := range entries {
-		bl.banned[entry.IP] = entry
+// add stores an entry. Callers must hold the lock.
+func (bl *IPBanList) add(prefix netip.Prefix, entry *BanEntry) {
+	if _, exists := bl.banned[prefix]; !exists {
+		bl.prefixLens[prefixLenKey(prefix)]++
 	}
+	bl.banned[prefix] = entry
+}
 
-	bl.logger.Info("Loaded ban list from file", "count", len(entries), "file", bl.filePath)
-	return nil
+// remove deletes an entry, reporting whether it existed. Callers must hold the lock.
+func (bl *IPBanList) remove(prefix netip.Prefix) bool {
+	if _, exists := bl.banned[prefix]; !exists {
+		return false
+	}
+	delete(bl.banned, prefix)
+
+	key := prefixLenKey(prefix)
+	if bl.prefixLens[key]--; bl.prefixLens[key] <= 0 {
+		delete(bl.prefixLens, key)
+	}
+	return true
 }
 
-// GetClientIP extracts the real client IP from the request.
-// It checks X-Forwarded-For, X-Real-IP headers, and falls back to RemoteAddr.
-func GetClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (common with reverse proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			if net.ParseIP(ip) != nil {
-				return ip
-			}
-		}
+// prefixLenKey returns the prefixLens key for a prefix.
+func prefixLenKey(prefix netip.Prefix) int {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() + ipv4LenOffset
 	}
+	return prefix.Bits()
+}
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		ip := strings.TrimSpace(xri)
-		if net.ParseIP(ip) != nil {
-			return ip
+// Count returns the number of active bans.
+func (bl *IPBanList) Count() int {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, entry := range bl.banned {
+		if !entry.IsExpired(now) {
+			count++
 		}
 	}
+	return count
+}
 
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
+// GetAll returns all active ban entries, oldest first.
+func (bl *IPBanList) GetAll() []*BanEntry {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
 
-	return ip
+	now := time.Now()
+	entries := make([]*BanEntry, 0, len(bl.banned))
+	for _, entry := range bl.banned {
+		if !entry.IsExpired(now) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].BannedAt.Before(entries[j].BannedAt)
+	})
+	return entries
 }
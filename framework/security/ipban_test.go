@@ -0,0 +1,115 @@
+package security
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBanList(t *testing.T, allowlist ...string) *IPBanList {
+	t.Helper()
+	banList, err := NewIPBanList(filepath.Join(t.TempDir(), "banned-ips.json"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewIPBanList: %v", err)
+	}
+	t.Cleanup(banList.Stop)
+	if err := banList.SetAllowlist(allowlist); err != nil {
+		t.Fatalf("SetAllowlist: %v", err)
+	}
+	return banList
+}
+
+func TestParseBanTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{target: "203.0.113.7", want: "203.0.113.7/32"},
+		{target: "  203.0.113.7 ", want: "203.0.113.7/32"},
+		{target: "2001:db8::1", want: "2001:db8::1/128"},
+		{target: "::ffff:203.0.113.7", want: "203.0.113.7/32"},
+		{target: "203.0.113.0/24", want: "203.0.113.0/24"},
+		{target: "203.0.113.77/24", want: "203.0.113.0/24"},
+		{target: "2001:db8::1/32", want: "2001:db8::/32"},
+		{target: "::ffff:203.0.113.0/120", want: "203.0.113.0/24"},
+		{target: "::ffff:0.0.0.0/96", want: "0.0.0.0/0"},
+		{target: "::ffff:0.0.0.0/80", wantErr: true},
+		{target: "203.0.113.0/33", wantErr: true},
+		{target: "203.0.113", wantErr: true},
+		{target: "example.com", wantErr: true},
+		{target: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := ParseBanTarget(tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseBanTarget(%q) = %v, want error", tt.target, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBanTarget(%q): %v", tt.target, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseBanTarget(%q) = %v, want %s", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPBanListLookup(t *testing.T) {
+	now := time.Now()
+	bans := []BanEntry{
+		{IP: "203.0.113.7"},
+		{IP: "198.51.100.0/24"},
+		{IP: "2001:db8::/32"},
+		{IP: "192.0.2.0/24", ExpiresAt: now.Add(time.Hour)},
+		{IP: "192.0.2.128/25", ExpiresAt: now.Add(-time.Hour)}, // Expired, must not hide the /24
+		{IP: "100.64.0.1", ExpiresAt: now.Add(-time.Hour)},
+		{IP: "10.0.0.0/8"},
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "203.0.113.7", want: true},
+		{ip: "203.0.113.8", want: false},
+		{ip: "::ffff:203.0.113.7", want: true},
+		{ip: "198.51.100.1", want: true},
+		{ip: "198.51.100.255", want: true},
+		{ip: "198.51.101.1", want: false},
+		{ip: "2001:db8:1::5", want: true},
+		{ip: "2001:db9::5", want: false},
+		{ip: "192.0.2.200", want: true},
+		{ip: "192.0.2.1", want: true},
+		{ip: "100.64.0.1", want: false},
+		{ip: "10.1.2.3", want: false}, // Allowlisted after the range was banned
+		{ip: "10.2.0.1", want: true},
+		{ip: "not-an-ip", want: false},
+	}
+
+	banList := newTestBanList(t)
+	for _, entry := range bans {
+		entry.BannedAt = now.Add(-2 * time.Hour)
+		if err := banList.Ban(entry); err != nil {
+			t.Fatalf("Ban(%s): %v", entry.IP, err)
+		}
+	}
+	if err := banList.SetAllowlist([]string{"10.1.0.0/16"}); err != nil {
+		t.Fatalf("SetAllowlist: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := banList.IsBanned(tt.ip); got != tt.want {
+				t.Errorf("IsBanned(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
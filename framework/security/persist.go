@@ -0,0 +1,326 @@
+package security
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Ban list persistence: the full list lives in a JSON snapshot that is only
+// ever replaced atomically, and changes since the last snapshot are appended
+// to a JSON-lines journal next to it. Changes are batched and written off the
+// request path; the journal is folded into a new snapshot periodically or
+// once it grows large.
+const (
+	flushDelay       = time.Second      // How long changes are batched before being journaled
+	compactThreshold = 1000             // Journal records that trigger a compaction
+	compactInterval  = 10 * time.Minute // Maximum time between compactions of a non-empty journal
+)
+
+// Journal operations.
+const (
+	journalBan   = "ban"
+	journalUnban = "unban"
+)
+
+// journalRecord is a single change in the ban list journal.
+type journalRecord struct {
+	Op    string    `json:"op"`
+	Entry *BanEntry `json:"entry,omitempty"` // Set for bans
+	IP    string    `json:"ip,omitempty"`    // Set for unbans
+}
+
+// journalPath returns the path of the journal file.
+func (bl *IPBanList) journalPath() string {
+	return bl.filePath + ".journal"
+}
+
+// record queues a change for the journal and schedules a flush.
+// Callers must hold the lock.
+func (bl *IPBanList) record(rec journalRecord) {
+	bl.pending = append(bl.pending, rec)
+	if bl.flushTimer == nil {
+		bl.flushTimer = time.AfterFunc(flushDelay, func() {
+			if err := bl.Flush(); err != nil {
+				bl.logger.Error("Failed to persist ban list", "error", err)
+			}
+		})
+	}
+}
+
+// Flush writes pending changes to the journal, compacting it into a new
+// snapshot once it has grown past the threshold.
+func (bl *IPBanList) Flush() error {
+	bl.persistMu.Lock()
+	defer bl.persistMu.Unlock()
+
+	bl.mu.Lock()
+	records := bl.pending
+	bl.pending = nil
+	if bl.flushTimer != nil {
+		bl.flushTimer.Stop()
+		bl.flushTimer = nil
+	}
+	compact := len(records) > 0 && bl.journalRecords+len(records) >= compactThreshold
+	var entries []*BanEntry
+	if compact {
+		entries = bl.snapshotEntries()
+	}
+	bl.mu.Unlock()
+
+	if compact {
+		if err := bl.compactTo(entries); err != nil {
+			bl.requeue(records)
+			return err
+		}
+		return nil
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := bl.appendJournal(records); err != nil {
+		bl.requeue(records)
+		return err
+	}
+
+	bl.mu.Lock()
+	bl.journalRecords += len(records)
+	bl.mu.Unlock()
+	return nil
+}
+
+// Compact writes the current ban list as a new snapshot and clears the journal.
+func (bl *IPBanList) Compact() error {
+	bl.persistMu.Lock()
+	defer bl.persistMu.Unlock()
+
+	bl.mu.Lock()
+	// The snapshot covers pending changes too
+	records := bl.pending
+	bl.pending = nil
+	if bl.flushTimer != nil {
+		bl.flushTimer.Stop()
+		bl.flushTimer = nil
+	}
+	entries := bl.snapshotEntries()
+	bl.mu.Unlock()
+
+	if err := bl.compactTo(entries); err != nil {
+		bl.requeue(records)
+		return err
+	}
+	return nil
+}
+
+// requeue puts records back in front of the pending ones after a failed
+// write, so the next flush retries them.
+func (bl *IPBanList) requeue(records []journalRecord) {
+	bl.mu.Lock()
+	bl.pending = append(records, bl.pending...)
+	bl.mu.Unlock()
+}
+
+// compactTo writes entries as the new snapshot and removes the journal.
+// Callers must hold persistMu.
+func (bl *IPBanList) compactTo(entries []*BanEntry) error {
+	if err := bl.writeSnapshot(entries); err != nil {
+		return err
+	}
+	if err := os.Remove(bl.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove ban list journal: %w", err)
+	}
+
+	bl.mu.Lock()
+	bl.journalRecords = 0
+	bl.lastCompaction = time.Now()
+	bl.mu.Unlock()
+
+	bl.logger.Debug("Compacted ban list", "count", len(entries))
+	return nil
+}
+
+// lastCompactionTime returns when the journal was last compacted.
+func (bl *IPBanList) lastCompactionTime() time.Time {
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+
+	return bl.lastCompaction
+}
+
+// snapshotEntries returns copies of all entries, oldest first.
+// Callers must hold the lock.
+func (bl *IPBanList) snapshotEntries() []*BanEntry {
+	entries := make([]*BanEntry, 0, len(bl.banned))
+	for _, entry := range bl.banned {
+		copied := *entry
+		entries = append(entries, &copied)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].BannedAt.Before(entries[j].BannedAt)
+	})
+	return entries
+}
+
+// writeSnapshot writes entries to a temporary file and renames it over the
+// snapshot, so a crash never leaves a partially written ban list.
+func (bl *IPBanList) writeSnapshot(entries []*BanEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ban list: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(bl.filePath), ".banned-ips-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create ban list file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write ban list: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync ban list: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write ban list: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), bl.filePath); err != nil {
+		return fmt.Errorf("failed to replace ban list: %w", err)
+	}
+	return nil
+}
+
+// appendJournal appends records to the journal in a single write.
+func (bl *IPBanList) appendJournal(records []journalRecord) error {
+	var data []byte
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal record: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	file, err := os.OpenFile(bl.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open ban list journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to write ban list journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync ban list journal: %w", err)
+	}
+	return nil
+}
+
+// load reads the snapshot and replays the journal on top of it.
+func (bl *IPBanList) load() error {
+	// Replay the journal even if the snapshot is unreadable to keep what we can
+	snapshotErr := bl.loadSnapshot()
+	if err := bl.replayJournal(); err != nil {
+		return err
+	}
+	if snapshotErr != nil {
+		return snapshotErr
+	}
+
+	bl.logger.Info("Loaded ban list from file",
+		"count", len(bl.banned),
+		"journal_records", bl.journalRecords,
+		"file", bl.filePath,
+	)
+	return nil
+}
+
+// loadSnapshot reads the ban list snapshot. A corrupt snapshot is moved
+// aside instead of being overwritten by the next save.
+func (bl *IPBanList) loadSnapshot() error {
+	data, err := os.ReadFile(bl.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // File doesn't exist yet, that's fine
+		}
+		return fmt.Errorf("failed to open ban list file: %w", err)
+	}
+
+	var entries []*BanEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		corruptPath := fmt.Sprintf("%s.corrupt-%d", bl.filePath, time.Now().Unix())
+		if renameErr := os.Rename(bl.filePath, corruptPath); renameErr != nil {
+			return fmt.Errorf("failed to decode ban list: %w (and failed to move it aside: %v)", err, renameErr)
+		}
+		return fmt.Errorf("failed to decode ban list, moved it to %s: %w", corruptPath, err)
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry == nil || entry.IsExpired(now) {
+			continue
+		}
+		bl.loadEntry(entry)
+	}
+	return nil
+}
+
+// replayJournal applies journaled changes made after the snapshot. A torn
+// final line from a crash mid-append is ignored.
+func (bl *IPBanList) replayJournal() error {
+	file, err := os.Open(bl.journalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open ban list journal: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			bl.logger.Warn("Ignoring unreadable ban list journal record", "line", line, "error", err)
+			continue
+		}
+
+		switch rec.Op {
+		case journalBan:
+			if rec.Entry != nil {
+				bl.loadEntry(rec.Entry)
+			}
+		case journalUnban:
+			if prefix, err := ParseBanTarget(rec.IP); err == nil {
+				bl.remove(prefix)
+			}
+		}
+		bl.journalRecords++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ban list journal: %w", err)
+	}
+	return nil
+}
+
+// loadEntry adds an entry read from disk, skipping invalid ones.
+func (bl *IPBanList) loadEntry(entry *BanEntry) {
+	prefix, err := ParseBanTarget(entry.IP)
+	if err != nil {
+		bl.logger.Warn("Skipping invalid ban entry", "ip", entry.IP, "error", err)
+		return
+	}
+	entry.IP = formatBanTarget(prefix)
+	bl.add(prefix, entry)
+}
@@ -0,0 +1,410 @@
+package security
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Threat signals recorded by the middleware.
+const (
+	SignalHoneypot        = "honeypot"
+	SignalNotFound        = "notFound"
+	SignalRateLimited     = "rateLimited"
+	SignalSuspiciousAgent = "suspiciousAgent"
+	SignalPathTraversal   = "pathTraversal"
+)
+
+// Threat level actions.
+const (
+	ActionTarpit = "tarpit"
+	ActionBan    = "ban"
+)
+
+const (
+	threatCleanupInterval = 5 * time.Minute
+	offenceMemory         = 7 * 24 * time.Hour // How long past bans count towards escalation
+	defaultThreatClients  = 10000
+)
+
+// ThreatConfig is the threat scoring configuration file.
+type ThreatConfig struct {
+	HalfLife          string             `json:"halfLife"`          // Time for a score to decay by half (e.g. "30m")
+	Weights           map[string]float64 `json:"weights"`           // Points per signal
+	SuspiciousAgents  []string           `json:"suspiciousAgents"`  // User-agent substrings of attack tools
+	TraversalPatterns []string           `json:"traversalPatterns"` // Request URI substrings of path traversal attempts
+	Levels            []ThreatLevel      `json:"levels"`            // Escalation thresholds
+	MaxClients        int                `json:"maxClients"`        // Clients tracked at once; the least recently seen is forgotten first
+}
+
+// ThreatLevel is an action taken once a client's score reaches Score.
+type ThreatLevel struct {
+	Score    float64 `json:"score"`
+	Action   string  `json:"action"`             // "tarpit" or "ban"
+	Delay    string  `json:"delay,omitempty"`    // Tarpit delay per request
+	Duration string  `json:"duration,omitempty"` // Ban duration; empty bans permanently
+}
+
+// threatLevel is a parsed ThreatLevel.
+type threatLevel struct {
+	score    float64
+	action   string
+	delay    time.Duration
+	duration time.Duration
+}
+
+// threatState is the score of a single client.
+type threatState struct {
+	ip          string
+	score       float64
+	updated     time.Time
+	banLevel    int // Index of the last ban level applied, -1 if never banned
+	bannedAt    time.Time
+	bannedUntil time.Time // Zero for a permanent ban
+}
+
+// banActive reports whether the last ban applied to the client still holds.
+func (s *threatState) banActive(now time.Time) bool {
+	if s.banLevel < 0 {
+		return false
+	}
+	return s.bannedUntil.IsZero() || now.Before(s.bannedUntil)
+}
+
+// ThreatScorer accumulates decaying threat scores per client IP and
+// escalates from tarpitting to temporary and long bans as scores grow.
+// Memory is bounded by forgetting the least recently seen client once
+// MaxClients are tracked.
+type ThreatScorer struct {
+	mu                sync.Mutex
+	clients           map[string]*list.Element
+	lru               *list.List // Front is most recently seen
+	maxClients        int
+	halfLife          time.Duration
+	weights           map[string]float64
+	levels            []threatLevel // Sorted by score
+	suspiciousAgents  []string
+	traversalPatterns []string
+	banList           *IPBanList
+	logger            *slog.Logger
+	cleanupTimer      *time.Timer
+	stopped           bool
+}
+
+// ThreatEvent describes a suspicious request.
+type ThreatEvent struct {
+	IP        string
+	Signal    string
+	Path      string
+	UserAgent string
+	Points    float64 // Overrides the signal's configured weight when set
+}
+
+// LoadThreatConfig reads the threat scoring configuration from a JSON file.
+func LoadThreatConfig(configFS fs.FS, filePath string) (ThreatConfig, error) {
+	var config ThreatConfig
+
+	data, err := fs.ReadFile(configFS, filePath)
+	if err != nil {
+		return config, fmt.Errorf("failed to read threats file: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse threats JSON: %w", err)
+	}
+	return config, nil
+}
+
+// NewThreatScorer creates a threat scorer that bans through banList.
+func NewThreatScorer(config ThreatConfig, banList *IPBanList, logger *slog.Logger) (*ThreatScorer, error) {
+	halfLife := 30 * time.Minute
+	if config.HalfLife != "" {
+		parsed, err := time.ParseDuration(config.HalfLife)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid halfLife %q", config.HalfLife)
+		}
+		halfLife = parsed
+	}
+
+	levels := make([]threatLevel, 0, len(config.Levels))
+	for _, level := range config.Levels {
+		parsed := threatLevel{score: level.Score, action: level.Action}
+		switch level.Action {
+		case ActionTarpit:
+			delay, err := time.ParseDuration(level.Delay)
+			if err != nil || delay <= 0 {
+				return nil, fmt.Errorf("invalid tarpit delay %q at score %v", level.Delay, level.Score)
+			}
+			parsed.delay = delay
+		case ActionBan:
+			if level.Duration != "" {
+				duration, err := time.ParseDuration(level.Duration)
+				if err != nil || duration <= 0 {
+					return nil, fmt.Errorf("invalid ban duration %q at score %v", level.Duration, level.Score)
+				}
+				parsed.duration = duration
+			}
+		default:
+			return nil, fmt.Errorf("unknown threat action %q at score %v", level.Action, level.Score)
+		}
+		levels = append(levels, parsed)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].score < levels[j].score })
+
+	maxClients := config.MaxClients
+	if maxClients <= 0 {
+		maxClients = defaultThreatClients
+	}
+
+	scorer := &ThreatScorer{
+		clients:           make(map[string]*list.Element),
+		lru:               list.New(),
+		maxClients:        maxClients,
+		halfLife:          halfLife,
+		weights:           config.Weights,
+		levels:            levels,
+		suspiciousAgents:  lowerAll(config.SuspiciousAgents),
+		traversalPatterns: lowerAll(config.TraversalPatterns),
+		banList:           banList,
+		logger:            logger,
+	}
+
+	// Start periodic cleanup of decayed scores
+	scorer.startCleanup()
+
+	return scorer, nil
+}
+
+// lowerAll returns the lowercased strings.
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
+
+// IsSuspiciousAgent reports whether a user agent belongs to a known attack tool.
+func (ts *ThreatScorer) IsSuspiciousAgent(userAgent string) bool {
+	return containsAny(strings.ToLower(userAgent), ts.suspiciousAgents)
+}
+
+// HasTraversal reports whether a raw request URI contains a path traversal pattern.
+func (ts *ThreatScorer) HasTraversal(requestURI string) bool {
+	return containsAny(strings.ToLower(requestURI), ts.traversalPatterns)
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if sub != "" && strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds the signal's weight to the client's score and bans the client
+// once a ban level is reached. Repeat offenders skip to the next ban level.
+// Allowlisted clients, such as trusted proxies, are never scored.
+func (ts *ThreatScorer) Record(event ThreatEvent) {
+	weight := ts.weights[event.Signal]
+	if event.Points > 0 {
+		weight = event.Points
+	}
+	if weight <= 0 || event.IP == "" || ts.banList.IsAllowlisted(event.IP) {
+		return
+	}
+
+	now := time.Now()
+
+	ts.mu.Lock()
+	state := ts.client(event.IP, true)
+	ts.decay(state, now)
+	state.score += weight
+	score := state.score
+
+	banIndex := -1
+	if level := ts.levelIndex(score); level >= 0 && ts.levels[level].action == ActionBan {
+		switch {
+		case state.banActive(now):
+			// Requests already in flight when the ban started; only a higher level upgrades it
+			if level > state.banLevel {
+				banIndex = level
+			}
+		case state.banLevel >= level && now.Sub(state.bannedAt) < offenceMemory:
+			banIndex = ts.nextBanLevel(state.banLevel)
+		default:
+			banIndex = level
+		}
+	}
+	if banIndex >= 0 {
+		state.banLevel = banIndex
+		state.bannedAt = now
+		state.bannedUntil = time.Time{}
+		if duration := ts.levels[banIndex].duration; duration > 0 {
+			state.bannedUntil = now.Add(duration)
+		}
+	}
+	ts.mu.Unlock()
+
+	ts.logger.Debug("Threat signal recorded",
+		"ip", event.IP,
+		"signal", event.Signal,
+		"score", math.Round(score),
+		"path", event.Path,
+	)
+
+	if banIndex < 0 {
+		return
+	}
+
+	level := ts.levels[banIndex]
+	entry := BanEntry{
+		IP:        event.IP,
+		Reason:    fmt.Sprintf("Threat score %.0f (%s)", score, event.Signal),
+		BannedAt:  now,
+		UserAgent: event.UserAgent,
+		Path:      event.Path,
+	}
+	if level.duration > 0 {
+		entry.ExpiresAt = now.Add(level.duration)
+	}
+
+	if err := ts.banList.Ban(entry); errors.Is(err, ErrAllowlisted) {
+		ts.logger.Info("Not banning allowlisted IP", "ip", event.IP, "score", math.Round(score))
+	} else if err != nil {
+		ts.logger.Error("Failed to ban IP", "ip", event.IP, "error", err)
+	}
+}
+
+// TarpitDelay returns how long to stall requests from a client, or zero.
+func (ts *ThreatScorer) TarpitDelay(ip string) time.Duration {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	state := ts.client(ip, false)
+	if state == nil {
+		return 0
+	}
+	ts.decay(state, time.Now())
+
+	var delay time.Duration
+	for _, level := range ts.levels {
+		if level.score > state.score {
+			break
+		}
+		if level.action == ActionTarpit && level.delay > delay {
+			delay = level.delay
+		}
+	}
+	return delay
+}
+
+// client returns the state of ip and marks it as recently seen. A missing
+// state is created when create is set, evicting the least recently seen
+// client if the scorer is full. Callers must hold the lock.
+func (ts *ThreatScorer) client(ip string, create bool) *threatState {
+	if element, ok := ts.clients[ip]; ok {
+		ts.lru.MoveToFront(element)
+		return element.Value.(*threatState)
+	}
+	if !create {
+		return nil
+	}
+
+	if ts.lru.Len() >= ts.maxClients {
+		ts.remove(ts.lru.Back())
+	}
+	state := &threatState{ip: ip, banLevel: -1}
+	ts.clients[ip] = ts.lru.PushFront(state)
+	return state
+}
+
+// remove forgets a client. Callers must hold the lock.
+func (ts *ThreatScorer) remove(element *list.Element) {
+	ts.lru.Remove(element)
+	delete(ts.clients, element.Value.(*threatState).ip)
+}
+
+// decay applies exponential decay to a score. Callers must hold the lock.
+func (ts *ThreatScorer) decay(state *threatState, now time.Time) {
+	if !state.updated.IsZero() {
+		elapsed := now.Sub(state.updated)
+		state.score *= math.Pow(0.5, float64(elapsed)/float64(ts.halfLife))
+	}
+	state.updated = now
+}
+
+// levelIndex returns the index of the highest level reached by score, or -1.
+func (ts *ThreatScorer) levelIndex(score float64) int {
+	index := -1
+	for i, level := range ts.levels {
+		if level.score > score {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// nextBanLevel returns the first ban level above index, or index itself if
+// it is already the highest.
+func (ts *ThreatScorer) nextBanLevel(index int) int {
+	for i := index + 1; i < len(ts.levels); i++ {
+		if ts.levels[i].action == ActionBan {
+			return i
+		}
+	}
+	return index
+}
+
+// startCleanup begins the periodic removal of decayed scores.
+func (ts *ThreatScorer) startCleanup() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.stopped {
+		return
+	}
+	ts.cleanupTimer = time.AfterFunc(threatCleanupInterval, func() {
+		ts.cleanup()
+		ts.startCleanup() // Reschedule
+	})
+}
+
+// cleanup forgets clients whose score has decayed away and whose last ban
+// no longer counts towards escalation.
+func (ts *ThreatScorer) cleanup() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := time.Now()
+	for element := ts.lru.Front(); element != nil; {
+		next := element.Next()
+		state := element.Value.(*threatState)
+		ts.decay(state, now)
+		if state.score < 1 && (state.banLevel < 0 || now.Sub(state.bannedAt) >= offenceMemory) {
+			ts.remove(element)
+		}
+		element = next
+	}
+}
+
+// Stop stops the cleanup timer.
+func (ts *ThreatScorer) Stop() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.stopped = true
+	if ts.cleanupTimer != nil {
+		ts.cleanupTimer.Stop()
+	}
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestThreatScorerSkipsAllowlistedClients(t *testing.T) {
+	// Trusted proxies are allowlisted by the server, so a request resolved
+	// to the proxy itself must never get it banned or tarpitted.
+	allowlist := []string{"127.0.0.1", "::1", "10.0.0.0/8"}
+
+	tests := []struct {
+		name       string
+		ip         string
+		wantBanned bool
+	}{
+		{name: "loopback proxy", ip: "127.0.0.1", wantBanned: false},
+		{name: "ipv6 loopback proxy", ip: "::1", wantBanned: false},
+		{name: "proxy range", ip: "10.1.2.3", wantBanned: false},
+		{name: "ipv4-mapped proxy", ip: "::ffff:127.0.0.1", wantBanned: false},
+		{name: "client", ip: "203.0.113.7", wantBanned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			banList := newTestBanList(t, allowlist...)
+			scorer, err := NewThreatScorer(ThreatConfig{
+				HalfLife: "30m",
+				Weights:  map[string]float64{SignalHoneypot: 40},
+				Levels: []ThreatLevel{
+					{Score: 30, Action: ActionTarpit, Delay: "3s"},
+					{Score: 60, Action: ActionBan, Duration: "1h"},
+				},
+			}, banList, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("NewThreatScorer: %v", err)
+			}
+			defer scorer.Stop()
+
+			for range 3 {
+				scorer.Record(ThreatEvent{IP: tt.ip, Signal: SignalHoneypot, Path: "/.env"})
+			}
+
+			if got := banList.IsBanned(tt.ip); got != tt.wantBanned {
+				t.Errorf("IsBanned(%s) = %v, want %v", tt.ip, got, tt.wantBanned)
+			}
+			if got := banList.Count(); (got > 0) != tt.wantBanned {
+				t.Errorf("Count() = %d, want ban stored = %v", got, tt.wantBanned)
+			}
+			if delay := scorer.TarpitDelay(tt.ip); (delay > 0) != tt.wantBanned {
+				t.Errorf("TarpitDelay(%s) = %v, want tarpit = %v", tt.ip, delay, tt.wantBanned)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"statigo/framework/security"
 )
 
 // PrettyJson formats data as indented JSON.
@@ -28,6 +30,13 @@ func SafeURL(s string) template.URL {
 	return template.URL(strings.TrimSpace(s))
 }
 
+// CSPNonce returns the CSP nonce marker. Rendered pages may be cached, so
+// Render swaps it for a per-render placeholder, and the CSP middleware swaps
+// that for the real per-request nonce on output.
+func CSPNonce() string {
+	return security.CSPNonceMarker()
+}
+
 // Add returns the sum of two integers.
 func Add(a, b int) int {
 	return a + b
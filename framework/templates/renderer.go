@@ -11,6 +11,7 @@ import (
 	"path"
 
 	"statigo/framework/dictionary"
+	"statigo/framework/security"
 	"statigo/framework/utils"
 )
 
@@ -56,6 +57,7 @@ func NewRenderer(templatesFS fs.FS, dict *dictionary.Dictionary, seoFuncs *SEOFu
 		"set":            Set,
 		"hasDiscount":    HasDiscount,
 		"t":              dict.GetRaw,
+		"cspNonce":       CSPNonce,
 	}
 
 	// Add SEO functions if provided
@@ -183,17 +185,21 @@ func (r *Renderer) Render(w http.ResponseWriter, templateName string, data inter
 		return
 	}
 
-	minifiedHTML, err := r.minifier.MinifyString("text/html", buf.String())
+	html, err := r.minifier.MinifyString("text/html", buf.String())
 	if err != nil {
 		r.logger.Error("Error minifying template", "template", templateName, "error", err)
 		// Fall back to unminified HTML
-		w.Header().Set("Content-Type", "text/html")
-		buf.WriteTo(w)
-		return
+		html = buf.String()
+	}
+
+	// Give the page its own nonce placeholder for the CSP middleware
+	html, placeholder := security.ReplaceCSPNonceMarker(html)
+	if placeholder != "" {
+		w.Header().Set(security.CSPPlaceholderHeader, placeholder)
 	}
 
 	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(minifiedHTML))
+	w.Write([]byte(html))
 }
 
 // loadTemplatesRecursivelyFromFS walks a directory in an fs.FS and loads all .html files as templates.
@@ -24,6 +24,15 @@ func GetEnvString(key string, defaultValue string) string {
 	return defaultValue
 }
 
+// LookupEnvString retrieves a string environment variable, using the default
+// only when the variable is unset so that an empty value can clear a setting.
+func LookupEnvString(key string, defaultValue string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return defaultValue
+}
+
 // GetEnvBool retrieves a boolean environment variable with a default value.
 func GetEnvBool(key string, defaultValue bool) bool {
 	if val := os.Getenv(key); val != "" {
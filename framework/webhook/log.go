@@ -0,0 +1,212 @@
+// Package webhook queues received webhook deliveries for background
+// processing and records them so they can be inspected and replayed.
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"statigo/framework/cache"
+)
+
+// Delivery statuses.
+const (
+	StatusOK      = "ok"      // Processed
+	StatusIgnored = "ignored" // Valid payload for an unknown entity
+	StatusInvalid = "invalid" // Payload could not be decoded
+	StatusFailed  = "failed"  // Processing failed after all retries
+)
+
+// ErrNotFound is returned when a delivery is not in the log.
+var ErrNotFound = errors.New("delivery not found")
+
+// Delivery is a received webhook and the outcome of processing it.
+type Delivery struct {
+	ID          string             `json:"id"`                   // Log ID, unique per processing
+	DeliveryID  string             `json:"deliveryId,omitempty"` // Sender's X-Webhook-Delivery header
+	ReplayOf    string             `json:"replayOf,omitempty"`   // Log ID of the replayed delivery
+	ReceivedAt  time.Time          `json:"receivedAt"`
+	Event       string             `json:"event,omitempty"`
+	Entity      string             `json:"entity,omitempty"`
+	Action      string             `json:"action,omitempty"`
+	Payload     json.RawMessage    `json:"payload,omitempty"`
+	Status      string             `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Invalidated int                `json:"invalidated"`
+	Coalesced   int                `json:"coalesced,omitempty"` // Deliveries handled in the same pass, including this one
+	DurationMs  float64            `json:"durationMs"`
+	DryRun      bool               `json:"dryRun,omitempty"`
+	Pages       []cache.CachedPage `json:"pages,omitempty"` // Pages a dry run would invalidate
+}
+
+// NewID returns a random delivery log ID.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Log is a bounded JSON-lines log of deliveries. Records are appended as
+// they arrive; once the file holds twice the limit it is rewritten with
+// only the newest entries.
+type Log struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	lines      int
+	logger     *slog.Logger
+}
+
+// OpenLog opens the delivery log at path, keeping at most maxEntries.
+func OpenLog(path string, maxEntries int, logger *slog.Logger) (*Log, error) {
+	if maxEntries <= 0 {
+		maxEntries = 500
+	}
+	l := &Log{path: path, maxEntries: maxEntries, logger: logger}
+
+	deliveries, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	l.lines = len(deliveries)
+	return l, nil
+}
+
+// Append adds a delivery to the log.
+func (l *Log) Append(delivery Delivery) error {
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write delivery log: %w", err)
+	}
+
+	l.lines++
+	if l.lines >= 2*l.maxEntries {
+		return l.trim()
+	}
+	return nil
+}
+
+// trim rewrites the log with only the newest entries. Callers must hold the lock.
+func (l *Log) trim() error {
+	deliveries, err := l.read()
+	if err != nil {
+		return err
+	}
+	if len(deliveries) > l.maxEntries {
+		deliveries = deliveries[len(deliveries)-l.maxEntries:]
+	}
+
+	var buf bytes.Buffer
+	for _, delivery := range deliveries {
+		line, err := json.Marshal(delivery)
+		if err != nil {
+			return fmt.Errorf("failed to encode delivery: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".webhook-deliveries-*")
+	if err != nil {
+		return fmt.Errorf("failed to create delivery log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write delivery log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write delivery log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("failed to replace delivery log: %w", err)
+	}
+
+	l.lines = len(deliveries)
+	return nil
+}
+
+// List returns logged deliveries, newest first.
+func (l *Log) List() ([]Delivery, error) {
+	l.mu.Lock()
+	deliveries, err := l.read()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+		deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+	}
+	if len(deliveries) > l.maxEntries {
+		deliveries = deliveries[:l.maxEntries]
+	}
+	return deliveries, nil
+}
+
+// Get returns the delivery with the given log ID.
+func (l *Log) Get(id string) (Delivery, error) {
+	deliveries, err := l.List()
+	if err != nil {
+		return Delivery{}, err
+	}
+	for _, delivery := range deliveries {
+		if delivery.ID == id {
+			return delivery, nil
+		}
+	}
+	return Delivery{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// read returns all deliveries in the file, oldest first. Unreadable lines,
+// such as a torn final write, are skipped.
+func (l *Log) read() ([]Delivery, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	defer file.Close()
+
+	var deliveries []Delivery
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var delivery Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &delivery); err != nil {
+			l.logger.Warn("Skipping unreadable delivery log record", "error", err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read delivery log: %w", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Queue errors.
+var (
+	ErrQueueFull   = errors.New("webhook queue is full")
+	ErrQueueClosed = errors.New("webhook queue is closed")
+)
+
+// QueueConfig configures the webhook queue.
+type QueueConfig struct {
+	Workers        int           // Batches processed concurrently
+	CoalesceWindow time.Duration // How long to collect deliveries into one batch
+	Capacity       int           // Deliveries waiting for a worker before Enqueue fails
+	MaxRetries     int           // Retries of a failed batch
+	RetryDelay     time.Duration // Delay before the first retry, doubled for each further one
+}
+
+// DefaultQueueConfig returns sensible defaults.
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Workers:        2,
+		CoalesceWindow: 2 * time.Second,
+		Capacity:       1000,
+		MaxRetries:     3,
+		RetryDelay:     time.Second,
+	}
+}
+
+// BatchHandler processes batches of queued deliveries.
+type BatchHandler interface {
+	// ProcessBatch handles a batch in one pass. An error retries the batch.
+	ProcessBatch(ctx context.Context, batch []Delivery) error
+	// BatchDone is called once per batch with the final result.
+	BatchDone(batch []Delivery, err error)
+}
+
+// Queue collects deliveries arriving within the coalesce window into a
+// single batch, so a burst of webhooks results in one processing pass, and
+// processes batches in the background with bounded concurrency.
+type Queue struct {
+	config  QueueConfig
+	handler BatchHandler
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	pending []Delivery
+	timer   *time.Timer // Set while the coalesce window is open
+	active  int         // Batches being processed
+	closed  bool
+	wg      sync.WaitGroup // One count per unfinished delivery
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewQueue creates a queue that hands batches to handler.
+func NewQueue(config QueueConfig, handler BatchHandler, logger *slog.Logger) *Queue {
+	defaults := DefaultQueueConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.CoalesceWindow <= 0 {
+		config.CoalesceWindow = defaults.CoalesceWindow
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = defaults.Capacity
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaults.RetryDelay
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		config:  config,
+		handler: handler,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Enqueue adds a delivery to the queue.
+func (q *Queue) Enqueue(delivery Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if len(q.pending) >= q.config.Capacity {
+		return ErrQueueFull
+	}
+
+	q.pending = append(q.pending, delivery)
+	q.wg.Add(1)
+
+	// The first delivery opens the coalesce window. Deliveries arriving while
+	// the window is closed but all workers are busy wait for the next free one.
+	if len(q.pending) == 1 && q.timer == nil {
+		q.timer = time.AfterFunc(q.config.CoalesceWindow, func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.timer = nil
+			q.dispatch()
+		})
+	}
+	return nil
+}
+
+// Len returns the number of deliveries waiting to be processed.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.pending)
+}
+
+// dispatch hands pending deliveries to a worker if one is free.
+// Callers must hold the lock.
+func (q *Queue) dispatch() {
+	if len(q.pending) == 0 || q.active >= q.config.Workers {
+		return
+	}
+	batch := q.pending
+	q.pending = nil
+	q.active++
+	go q.run(batch)
+}
+
+// run processes a batch, retrying with exponential backoff.
+func (q *Queue) run(batch []Delivery) {
+	err := q.process(batch)
+	for attempt := 1; err != nil && attempt <= q.config.MaxRetries; attempt++ {
+		delay := q.config.RetryDelay << (attempt - 1)
+		q.logger.Warn("Webhook batch failed, retrying",
+			"deliveries", len(batch),
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+
+		select {
+		case <-time.After(delay):
+			err = q.process(batch)
+			continue
+		case <-q.ctx.Done():
+			err = fmt.Errorf("%w (retry abandoned on shutdown)", err)
+		}
+		break
+	}
+
+	if err != nil {
+		q.logger.Error("Webhook batch failed", "deliveries", len(batch), "error", err)
+	}
+	q.handler.BatchDone(batch, err)
+
+	q.mu.Lock()
+	q.active--
+	if q.timer == nil {
+		q.dispatch()
+	}
+	q.mu.Unlock()
+	q.wg.Add(-len(batch))
+}
+
+// process runs the handler once, turning a panic into an error.
+func (q *Queue) process(batch []Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing webhooks: %v", r)
+		}
+	}()
+	return q.handler.ProcessBatch(q.ctx, batch)
+}
+
+// Drain stops accepting deliveries, processes those already queued without
+// waiting for the coalesce window, and waits for all batches to finish.
+// When ctx expires first, retries are abandoned and deliveries still waiting
+// for a worker are reported as failed.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	q.dispatch()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.cancel()
+	q.mu.Lock()
+	batch := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	if len(batch) > 0 {
+		q.handler.BatchDone(batch, fmt.Errorf("queue drained before processing: %w", ctx.Err()))
+		q.wg.Add(-len(batch))
+	}
+	return fmt.Errorf("failed to drain webhook queue: %w", ctx.Err())
+}
@@ -45,16 +45,16 @@ type BlogPostData struct {
 
 type BlogPostHandler struct {
 	renderer     *templates.Renderer
-	bloggo       *services.BloggoService
+	content      services.ContentSource
 	apiBase      string
 	viewTracker  *services.ViewTracker
 	viewsHandler *ViewsHandler
 }
 
-func NewBlogPostHandler(renderer *templates.Renderer, bloggo *services.BloggoService, apiBase string, viewTracker *services.ViewTracker, viewsHandler *ViewsHandler) *BlogPostHandler {
+func NewBlogPostHandler(renderer *templates.Renderer, content services.ContentSource, apiBase string, viewTracker *services.ViewTracker, viewsHandler *ViewsHandler) *BlogPostHandler {
 	return &BlogPostHandler{
 		renderer:     renderer,
-		bloggo:       bloggo,
+		content:      content,
 		apiBase:      apiBase,
 		viewTracker:  viewTracker,
 		viewsHandler: viewsHandler,
@@ -73,20 +73,23 @@ func (h *BlogPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	slug = strings.TrimSuffix(slug, "/")
 
 	// Fetch post from API
-	post, err := h.bloggo.GetPost(r.Context(), slug)
+	post, err := h.content.GetPost(r.Context(), slug)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		data := BaseData(lang, t)
-		data["Title"] = t("pages.notfound.title")
-		data["Content"] = map[string]string{
-			"heading": t("pages.notfound.heading"),
-			"message": t("pages.notfound.message"),
-			"action":  t("pages.notfound.action"),
-		}
-		h.renderer.Render(w, "notfound.html", data)
+		renderContentError(w, h.renderer, lang, err)
 		return
 	}
 
+	// Declare what this page renders so webhooks can invalidate it
+	fwctx.AddCacheTags(r.Context(),
+		postTag(slug),
+		categoryTag(post.Category.Slug),
+		authorTag(post.Author.ID),
+		postListTag,
+	)
+	for _, tag := range post.Tags {
+		fwctx.AddCacheTags(r.Context(), tagTag(tag.Slug))
+	}
+
 	cover := ""
 	if post.CoverImage != nil {
 		cover = h.apiBase + *post.CoverImage
@@ -177,7 +180,7 @@ func (h *BlogPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Fetch related posts (same category)
-	related, err := h.bloggo.ListPosts(r.Context(), services.ListPostsParams{
+	related, err := h.content.ListPosts(r.Context(), services.ListPostsParams{
 		Category: post.Category.Slug,
 		Limit:    4,
 	})
@@ -215,13 +218,14 @@ func (h *BlogPostHandler) ViewTrackingMiddleware(next http.Handler) http.Handler
 		path := r.URL.Path
 		if strings.HasPrefix(path, "/blogs/") {
 			slug := strings.TrimSuffix(strings.TrimPrefix(path, "/blogs/"), "/")
-			if slug != "" {
+			counter, counts := h.content.(services.ViewCounter)
+			if slug != "" && counts {
 				ua := r.Header.Get("User-Agent")
 				go func() {
 					if h.viewTracker.ShouldTrackView(r, slug) {
 						ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 						defer cancel()
-						_ = h.bloggo.TrackView(ctx, slug, ua)
+						_ = counter.TrackView(ctx, slug, ua)
 					}
 				}()
 			}
@@ -29,15 +29,15 @@ type PageNumber struct {
 
 type BlogsHandler struct {
 	renderer     *templates.Renderer
-	bloggo       *services.BloggoService
+	content      services.ContentSource
 	apiBase      string
 	postsPerPage int
 }
 
-func NewBlogsHandler(renderer *templates.Renderer, bloggo *services.BloggoService, apiBase string) *BlogsHandler {
+func NewBlogsHandler(renderer *templates.Renderer, content services.ContentSource, apiBase string) *BlogsHandler {
 	return &BlogsHandler{
 		renderer:     renderer,
-		bloggo:       bloggo,
+		content:      content,
 		apiBase:      apiBase,
 		postsPerPage: utils.GetEnvInt("BLOGS_PAGE_SIZE", 12),
 	}
@@ -97,10 +97,11 @@ func (h *BlogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	data["ClearFiltersHref"] = "/blogs"
 
 	// Fetch categories — clicking active deselects
-	categories, err := h.bloggo.ListCategories(r.Context())
+	categories, err := h.content.ListCategories(r.Context())
 	if err == nil {
 		var blogCategories []BlogCategory
 		for _, cat := range categories {
+			fwctx.AddCacheTags(r.Context(), categoryTag(cat.Slug))
 			active := category == cat.Slug
 			href := buildFilterURL(cat.Slug, tag, search)
 			if active {
@@ -118,10 +119,11 @@ func (h *BlogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch tags — clicking active deselects
-	tags, err := h.bloggo.ListTags(r.Context())
+	tags, err := h.content.ListTags(r.Context())
 	if err == nil {
 		var blogTags []BlogTag
 		for _, tg := range tags {
+			fwctx.AddCacheTags(r.Context(), tagTag(tg.Slug))
 			active := tag == tg.Slug
 			href := buildFilterURL(category, tg.Slug, search)
 			if active {
@@ -139,7 +141,7 @@ func (h *BlogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch posts
-	postsResp, err := h.bloggo.ListPosts(r.Context(), services.ListPostsParams{
+	postsResp, err := h.content.ListPosts(r.Context(), services.ListPostsParams{
 		Page:     currentPage,
 		Limit:    h.postsPerPage,
 		Category: category,
@@ -148,12 +150,15 @@ func (h *BlogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		h.renderer.Render(w, "blogs.html", data)
+		renderContentError(w, h.renderer, lang, err)
 		return
 	}
 
+	fwctx.AddCacheTags(r.Context(), postListTag)
+
 	var blogs []BlogPost
 	for _, p := range postsResp.Data {
+		fwctx.AddCacheTags(r.Context(), postTag(p.Slug))
 		cover := ""
 		if p.CoverImage != nil {
 			cover = h.apiBase + *p.CoverImage
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"strconv"
+)
+
+// Cache tags declared by handlers and targeted by webhook invalidation.
+const postListTag = "posts" // Pages that render a list of posts
+
+func postTag(slug string) string {
+	return "post:" + slug
+}
+
+// categoryTag returns "" for uncategorized posts, which AddCacheTags skips.
+func categoryTag(slug string) string {
+	if slug == "" {
+		return ""
+	}
+	return "category:" + slug
+}
+
+func tagTag(slug string) string {
+	return "tag:" + slug
+}
+
+func authorTag(id int) string {
+	return "author:" + strconv.Itoa(id)
+}
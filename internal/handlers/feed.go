@@ -32,14 +32,14 @@ type rssItem struct {
 }
 
 type FeedHandler struct {
-	bloggo  *services.BloggoService
+	content services.ContentSource
 	apiBase string
 	siteURL string
 }
 
-func NewFeedHandler(bloggo *services.BloggoService, apiBase string, siteURL string) *FeedHandler {
+func NewFeedHandler(content services.ContentSource, apiBase string, siteURL string) *FeedHandler {
 	return &FeedHandler{
-		bloggo:  bloggo,
+		content: content,
 		apiBase: apiBase,
 		siteURL: siteURL,
 	}
@@ -48,7 +48,7 @@ func NewFeedHandler(bloggo *services.BloggoService, apiBase string, siteURL stri
 func (h *FeedHandler) RSS(w http.ResponseWriter, r *http.Request) {
 	posts, err := h.fetchPosts(r)
 	if err != nil {
-		http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
+		writeUnavailable(w)
 		return
 	}
 
@@ -88,11 +88,14 @@ func (h *FeedHandler) fetchPosts(r *http.Request) ([]services.PostSummary, error
 	var all []services.PostSummary
 
 	for page := 1; ; page++ {
-		resp, err := h.bloggo.ListPosts(r.Context(), services.ListPostsParams{
+		resp, err := h.content.ListPosts(r.Context(), services.ListPostsParams{
 			Page:  page,
 			Limit: 100,
 		})
-		if err != nil || len(resp.Data) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) == 0 {
 			break
 		}
 		all = append(all, resp.Data...)
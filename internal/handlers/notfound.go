@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"statigo/framework/templates"
+	"statigo/internal/services"
 )
 
+// retryAfterSeconds is how long clients are asked to wait before retrying a
+// page the content source could not provide.
+const retryAfterSeconds = 60
+
 type NotFoundHandler struct {
 	renderer *templates.Renderer
 }
@@ -17,21 +24,56 @@ func NewNotFoundHandler(renderer *templates.Renderer) *NotFoundHandler {
 }
 
 func (h *NotFoundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	const lang = "en"
+	renderNotFound(w, h.renderer, "en")
+}
+
+// renderContentError renders the 404 page when err means the content does
+// not exist or can't be requested, the 503 page when the content source
+// could not answer, and a plain 500 otherwise.
+func renderContentError(w http.ResponseWriter, renderer *templates.Renderer, lang string, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound), errors.Is(err, services.ErrBadRequest):
+		renderNotFound(w, renderer, lang)
+	case errors.Is(err, services.ErrUnavailable):
+		renderUnavailable(w, renderer, lang)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
 
+// renderNotFound renders the 404 page.
+func renderNotFound(w http.ResponseWriter, renderer *templates.Renderer, lang string) {
 	w.WriteHeader(http.StatusNotFound)
+	renderErrorPage(w, renderer, lang, "notfound", "notfound.html")
+}
+
+// renderUnavailable renders the 503 page, asking clients to retry later.
+func renderUnavailable(w http.ResponseWriter, renderer *templates.Renderer, lang string) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	renderErrorPage(w, renderer, lang, "unavailable", "unavailable.html")
+}
+
+// writeUnavailable answers a non-HTML request with a plain 503, asking
+// clients to retry later.
+func writeUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	http.Error(w, "Content temporarily unavailable", http.StatusServiceUnavailable)
+}
 
+// renderErrorPage renders an error page with texts from pages.<key>.
+func renderErrorPage(w http.ResponseWriter, renderer *templates.Renderer, lang, key, page string) {
 	t := func(key string) string {
-		return h.renderer.GetTranslation(lang, key)
+		return renderer.GetTranslation(lang, key)
 	}
 
 	data := BaseData(lang, t)
-	data["Title"] = t("pages.notfound.title")
+	data["Title"] = t("pages." + key + ".title")
 	data["Content"] = map[string]string{
-		"heading": t("pages.notfound.heading"),
-		"message": t("pages.notfound.message"),
-		"action":  t("pages.notfound.action"),
+		"heading": t("pages." + key + ".heading"),
+		"message": t("pages." + key + ".message"),
+		"action":  t("pages." + key + ".action"),
 	}
 
-	h.renderer.Render(w, "notfound.html", data)
+	renderer.Render(w, page, data)
 }
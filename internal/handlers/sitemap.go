@@ -20,12 +20,12 @@ type sitemapURL struct {
 }
 
 type SitemapHandler struct {
-	bloggo  *services.BloggoService
+	content services.ContentSource
 	siteURL string
 }
 
-func NewSitemapHandler(bloggo *services.BloggoService, siteURL string) *SitemapHandler {
-	return &SitemapHandler{bloggo: bloggo, siteURL: siteURL}
+func NewSitemapHandler(content services.ContentSource, siteURL string) *SitemapHandler {
+	return &SitemapHandler{content: content, siteURL: siteURL}
 }
 
 func (h *SitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -37,11 +37,15 @@ func (h *SitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	page := 1
 	for {
-		resp, err := h.bloggo.ListPosts(r.Context(), services.ListPostsParams{
+		resp, err := h.content.ListPosts(r.Context(), services.ListPostsParams{
 			Page:  page,
 			Limit: 100,
 		})
-		if err != nil || len(resp.Data) == 0 {
+		if err != nil {
+			writeUnavailable(w)
+			return
+		}
+		if len(resp.Data) == 0 {
 			break
 		}
 		for _, p := range resp.Data {
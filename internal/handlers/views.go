@@ -17,20 +17,25 @@ type ViewsCache struct {
 	data       map[string]int
 	expiresAt  time.Time
 	ttl        time.Duration
-	bloggo     *services.BloggoService
+	counter    services.ViewCounter
 }
 
-// NewViewsCache creates a new views cache.
-func NewViewsCache(bloggo *services.BloggoService, ttl time.Duration) *ViewsCache {
+// NewViewsCache creates a new views cache. With a nil counter, no post
+// has views.
+func NewViewsCache(counter services.ViewCounter, ttl time.Duration) *ViewsCache {
 	return &ViewsCache{
-		data:   make(map[string]int),
-		ttl:    ttl,
-		bloggo: bloggo,
+		data:    make(map[string]int),
+		ttl:     ttl,
+		counter: counter,
 	}
 }
 
 // Get retrieves view counts, refreshing from API if cache is expired.
 func (vc *ViewsCache) Get() (map[string]int, error) {
+	if vc.counter == nil {
+		return map[string]int{}, nil
+	}
+
 	vc.mu.RLock()
 	needsRefresh := time.Now().After(vc.expiresAt)
 	currentData := vc.data
@@ -53,7 +58,7 @@ func (vc *ViewsCache) Get() (map[string]int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	freshData, err := vc.bloggo.GetViewCounts(ctx)
+	freshData, err := vc.counter.GetViewCounts(ctx)
 	if err != nil {
 		// Return stale data if available
 		if len(vc.data) > 0 {
@@ -82,9 +87,9 @@ type ViewsHandler struct {
 }
 
 // NewViewsHandler creates a new views handler.
-func NewViewsHandler(bloggo *services.BloggoService, ttl time.Duration) *ViewsHandler {
+func NewViewsHandler(counter services.ViewCounter, ttl time.Duration) *ViewsHandler {
 	return &ViewsHandler{
-		Cache: NewViewsCache(bloggo, ttl),
+		Cache: NewViewsCache(counter, ttl),
 	}
 }
 
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"statigo/framework/cache"
+	"statigo/framework/middleware"
+	"statigo/framework/webhook"
 )
 
 // WebhookPayload matches the payload structure sent by Bloggo CMS.
@@ -22,94 +30,479 @@ type WebhookPayload struct {
 
 // WebhookHandler handles incoming webhooks from Bloggo CMS for cache invalidation.
 type WebhookHandler struct {
-	cacheManager *cache.Manager
-	viewsHandler *ViewsHandler
-	logger       *slog.Logger
+	cacheManager  *cache.Manager
+	viewsHandler  *ViewsHandler
+	redirectStore *middleware.RedirectStore
+	deliveryLog   *webhook.Log
+	queue         *webhook.Queue
+	logger        *slog.Logger
 }
 
 // NewWebhookHandler creates a new webhook handler.
-func NewWebhookHandler(cacheManager *cache.Manager, viewsHandler *ViewsHandler, logger *slog.Logger) *WebhookHandler {
+func NewWebhookHandler(cacheManager *cache.Manager, viewsHandler *ViewsHandler, redirectStore *middleware.RedirectStore, logger *slog.Logger) *WebhookHandler {
 	return &WebhookHandler{
-		cacheManager: cacheManager,
-		viewsHandler: viewsHandler,
-		logger:       logger,
+		cacheManager:  cacheManager,
+		viewsHandler:  viewsHandler,
+		redirectStore: redirectStore,
+		logger:        logger,
 	}
 }
 
-// Handle processes incoming webhook events from Bloggo CMS.
-func (h *WebhookHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-
-	var payload WebhookPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		h.logger.Warn("webhook: invalid payload", slog.String("error", err.Error()))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid payload"})
-		return
-	}
-
-	h.logger.Info("webhook received",
-		slog.String("event", payload.Event),
-		slog.String("entity", payload.Entity),
-		slog.String("action", payload.Action),
-	)
+// SetDeliveryLog enables recording of received webhooks.
+func (h *WebhookHandler) SetDeliveryLog(log *webhook.Log) {
+	h.deliveryLog = log
+}
 
-	invalidated := 0
+// SetQueue makes Handle acknowledge webhooks immediately and process them
+// through the queue. The queue should be created with this handler.
+func (h *WebhookHandler) SetQueue(queue *webhook.Queue) {
+	h.queue = queue
+}
 
+// invalidationTags returns the cache tags affected by a webhook event.
+// It returns false when the event can't be narrowed down and the whole
+// cache has to be invalidated.
+func invalidationTags(payload WebhookPayload) ([]string, bool) {
 	switch payload.Entity {
 	case "post":
-		// Post changes affect blog listing and home page (recent posts)
-		if h.cacheManager != nil {
-			invalidated = h.cacheManager.MarkStale("static", true)
+		if payload.Slug == nil {
+			return nil, false
+		}
+		// The post page itself plus the home page and listings
+		tags := []string{
+			postTag(*payload.Slug),
+			cache.PathTag("/blogs/" + *payload.Slug),
+			postListTag,
+			cache.PathTag("/"),
+			cache.PathTag("/blogs"),
+		}
+		if payload.OldSlug != nil && *payload.OldSlug != *payload.Slug {
+			tags = append(tags, postTag(*payload.OldSlug), cache.PathTag("/blogs/"+*payload.OldSlug))
 		}
-		// Also invalidate views cache since post list may change
-		if h.viewsHandler != nil {
-			h.viewsHandler.InvalidateCache()
+		return tags, true
+
+	case "category":
+		if payload.Slug == nil {
+			return nil, false
 		}
+		return []string{categoryTag(*payload.Slug)}, true
 
-	case "category", "tag":
-		// Category/tag changes affect blog listing filters
-		if h.cacheManager != nil {
-			invalidated = h.cacheManager.MarkStale("static", true)
+	case "tag":
+		if payload.Slug == nil {
+			return nil, false
 		}
+		return []string{tagTag(*payload.Slug)}, true
 
 	case "author":
-		// Author data appears on cached blog post detail pages
-		if h.cacheManager != nil {
-			invalidated = h.cacheManager.MarkStale("static", true)
+		if payload.ID == nil {
+			return nil, false
 		}
+		return []string{authorTag(int(*payload.ID))}, true
+	}
+
+	return nil, false
+}
+
+// invalidationPlan collects the work of one or more webhook events so a
+// batch of them is applied in a single invalidation pass.
+type invalidationPlan struct {
+	tags         map[string]bool
+	all          bool // The whole cache has to be invalidated
+	refreshViews bool
+	slugChanges  []WebhookPayload
+}
 
+// add folds an event into the plan. It returns false for unknown entities.
+func (p *invalidationPlan) add(payload WebhookPayload) bool {
+	switch payload.Entity {
+	case "post":
+		// Post changes affect the post page, blog listing and home page,
+		// and the views cache since the post list may change
+		p.slugChanges = append(p.slugChanges, payload)
+		p.refreshViews = true
+	case "category", "tag", "author":
+		// Only pages that render this category, tag or author
 	case "keyvalue":
 		// Site-wide config changes, invalidate everything
-		if h.cacheManager != nil {
-			invalidated = h.cacheManager.MarkAllStale(true)
-		}
-
 	case "cms":
 		// Manual sync - full invalidation
-		if h.cacheManager != nil {
-			invalidated = h.cacheManager.MarkAllStale(true)
+		p.refreshViews = true
+	default:
+		return false
+	}
+
+	tags, ok := invalidationTags(payload)
+	if !ok {
+		p.all = true
+		return true
+	}
+	if p.tags == nil {
+		p.tags = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		p.tags[tag] = true
+	}
+	return true
+}
+
+// apply records slug changes and marks the planned pages stale, revalidating
+// them in the background. On a dry run it changes nothing and only returns
+// the pages that would be marked.
+func (h *WebhookHandler) apply(plan *invalidationPlan, dryRun bool) (int, []cache.CachedPage, error) {
+	var err error
+	if !dryRun {
+		for _, payload := range plan.slugChanges {
+			if slugErr := h.recordSlugChange(payload); slugErr != nil {
+				err = slugErr
+			}
+		}
+	}
+
+	invalidated, pages := h.invalidate(plan, dryRun)
+	if plan.refreshViews && h.viewsHandler != nil && !dryRun {
+		h.viewsHandler.InvalidateCache()
+	}
+	return invalidated, pages, err
+}
+
+// invalidate marks the planned pages stale, or lists them on a dry run.
+func (h *WebhookHandler) invalidate(plan *invalidationPlan, dryRun bool) (int, []cache.CachedPage) {
+	if h.cacheManager == nil || (!plan.all && len(plan.tags) == 0) {
+		return 0, nil
+	}
+	tags := make([]string, 0, len(plan.tags))
+	for tag := range plan.tags {
+		tags = append(tags, tag)
+	}
+
+	if dryRun {
+		var pages []cache.CachedPage
+		if plan.all {
+			pages = h.cacheManager.AllPages()
+		} else {
+			pages = h.cacheManager.PagesForTags(tags...)
 		}
-		if h.viewsHandler != nil {
-			h.viewsHandler.InvalidateCache()
+		return len(pages), pages
+	}
+	if plan.all {
+		return h.cacheManager.MarkAllStale(true), nil
+	}
+	return h.cacheManager.MarkTagsStale(true, tags...), nil
+}
+
+// recordSlugChange stores a permanent redirect from a post's old URL to its
+// new one so links and search rankings keep working. The post's URL is live,
+// so a redirect away from it, left by an earlier rename, is dropped even
+// when the slug did not change.
+func (h *WebhookHandler) recordSlugChange(payload WebhookPayload) error {
+	if h.redirectStore == nil || payload.Slug == nil || *payload.Slug == "" {
+		return nil
+	}
+
+	target := "/blogs/" + *payload.Slug
+	if payload.OldSlug == nil || *payload.OldSlug == "" || *payload.OldSlug == *payload.Slug {
+		if err := h.redirectStore.Remove(target); err != nil {
+			h.logger.Error("webhook: failed to remove redirect from live post",
+				slog.String("source", target),
+				slog.String("error", err.Error()),
+			)
+			return fmt.Errorf("failed to remove redirect: %w", err)
 		}
+		return nil
+	}
 
-	default:
+	// Add drops any redirect away from target
+	source := "/blogs/" + *payload.OldSlug
+	if err := h.redirectStore.Add(source, target); err != nil {
+		h.logger.Error("webhook: failed to record slug redirect",
+			slog.String("source", source),
+			slog.String("target", target),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to record slug redirect: %w", err)
+	}
+	return nil
+}
+
+// decode parses a webhook body into a new delivery. Undecodable bodies
+// yield a delivery with StatusInvalid.
+func (h *WebhookHandler) decode(body []byte) (webhook.Delivery, WebhookPayload) {
+	delivery := webhook.Delivery{
+		ID:         webhook.NewID(),
+		ReceivedAt: time.Now(),
+		Payload:    json.RawMessage(body),
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.logger.Warn("webhook: invalid payload", slog.String("error", err.Error()))
+		delivery.Status = webhook.StatusInvalid
+		delivery.Error = err.Error()
+		if !json.Valid(body) {
+			delivery.Payload = nil
+		}
+		return delivery, payload
+	}
+
+	delivery.Event = payload.Event
+	delivery.Entity = payload.Entity
+	delivery.Action = payload.Action
+	return delivery, payload
+}
+
+// process decodes a webhook body and applies it immediately.
+func (h *WebhookHandler) process(body []byte, dryRun bool) webhook.Delivery {
+	delivery, payload := h.decode(body)
+	delivery.DryRun = dryRun
+	if delivery.Status == webhook.StatusInvalid {
+		return delivery
+	}
+
+	plan := &invalidationPlan{}
+	if !plan.add(payload) {
 		h.logger.Warn("webhook: unknown entity", slog.String("entity", payload.Entity))
+		delivery.Status = webhook.StatusIgnored
+		return delivery
 	}
 
-	h.logger.Info("webhook processed",
-		slog.String("event", payload.Event),
+	invalidated, pages, err := h.apply(plan, dryRun)
+	delivery.Invalidated = invalidated
+	delivery.Pages = pages
+	delivery.Status = webhook.StatusOK
+	if err != nil {
+		delivery.Status = webhook.StatusFailed
+		delivery.Error = err.Error()
+	}
+	delivery.DurationMs = float64(time.Since(delivery.ReceivedAt).Microseconds()) / 1000
+	return delivery
+}
+
+// ProcessBatch applies a batch of queued deliveries in one invalidation pass
+// and records the outcome on each delivery.
+func (h *WebhookHandler) ProcessBatch(ctx context.Context, batch []webhook.Delivery) error {
+	start := time.Now()
+	plan := &invalidationPlan{}
+	known := make([]bool, len(batch))
+	for i, delivery := range batch {
+		var payload WebhookPayload
+		if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+			continue
+		}
+		known[i] = plan.add(payload)
+		if !known[i] {
+			h.logger.Warn("webhook: unknown entity", slog.String("entity", payload.Entity))
+		}
+	}
+
+	invalidated, _, err := h.apply(plan, false)
+	duration := float64(time.Since(start).Microseconds()) / 1000
+	for i := range batch {
+		batch[i].Status = webhook.StatusOK
+		if !known[i] {
+			batch[i].Status = webhook.StatusIgnored
+		}
+		batch[i].Invalidated = invalidated
+		batch[i].Coalesced = len(batch)
+		batch[i].DurationMs = duration
+	}
+	return err
+}
+
+// BatchDone logs and records the final outcome of a batch.
+func (h *WebhookHandler) BatchDone(batch []webhook.Delivery, err error) {
+	invalidated := 0
+	for _, delivery := range batch {
+		if err != nil {
+			delivery.Status = webhook.StatusFailed
+			delivery.Error = err.Error()
+		}
+		invalidated = delivery.Invalidated
+		h.record(delivery)
+	}
+
+	if err != nil {
+		return
+	}
+	h.logger.Info("webhooks processed",
+		slog.Int("deliveries", len(batch)),
 		slog.Int("invalidated", invalidated),
 	)
+}
+
+// record appends a delivery to the delivery log, if one is set.
+func (h *WebhookHandler) record(delivery webhook.Delivery) {
+	if h.deliveryLog == nil || delivery.DryRun {
+		return
+	}
+	if err := h.deliveryLog.Append(delivery); err != nil {
+		h.logger.Error("webhook: failed to record delivery",
+			slog.String("id", delivery.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Handle accepts webhook events from Bloggo CMS. With a queue set, valid
+// events are acknowledged with 202 and processed in the background.
+func (h *WebhookHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.Warn("webhook: failed to read body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if h.queue == nil {
+		delivery := h.process(body, false)
+		delivery.DeliveryID = r.Header.Get(middleware.WebhookDeliveryHeader)
+		h.record(delivery)
+		h.respond(w, delivery)
+		return
+	}
+
+	delivery, _ := h.decode(body)
+	delivery.DeliveryID = r.Header.Get(middleware.WebhookDeliveryHeader)
+	if delivery.Status == webhook.StatusInvalid {
+		h.record(delivery)
+		h.respond(w, delivery)
+		return
+	}
+
+	if err := h.queue.Enqueue(delivery); err != nil {
+		h.logger.Error("webhook: failed to queue delivery",
+			slog.String("id", delivery.ID),
+			slog.String("error", err.Error()),
+		)
+		delivery.Status = webhook.StatusFailed
+		delivery.Error = err.Error()
+		h.record(delivery)
 
+		// Ask the sender to retry later
+		w.Header().Set("Retry-After", "5")
+		writeWebhookError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	h.logger.Info("webhook queued",
+		slog.String("id", delivery.ID),
+		slog.String("event", delivery.Event),
+		slog.String("entity", delivery.Entity),
+		slog.String("action", delivery.Action),
+	)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]any{
-		"success":     true,
-		"invalidated": invalidated,
+		"success":  true,
+		"delivery": delivery.ID,
+		"queued":   true,
 	})
 }
+
+// respond writes the result of a synchronously processed delivery.
+func (h *WebhookHandler) respond(w http.ResponseWriter, delivery webhook.Delivery) {
+	if delivery.Status == webhook.StatusInvalid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid payload"})
+		return
+	}
+
+	h.logger.Info("webhook processed",
+		slog.String("id", delivery.ID),
+		slog.String("event", delivery.Event),
+		slog.String("entity", delivery.Entity),
+		slog.String("action", delivery.Action),
+		slog.Int("invalidated", delivery.Invalidated),
+		slog.Float64("duration_ms", delivery.DurationMs),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":     delivery.Status != webhook.StatusFailed,
+		"delivery":    delivery.ID,
+		"invalidated": delivery.Invalidated,
+	})
+}
+
+// Replay processes a logged delivery again. A dry run reports the pages
+// that would be invalidated without changing anything or being logged.
+func (h *WebhookHandler) Replay(id string, dryRun bool) (webhook.Delivery, error) {
+	if h.deliveryLog == nil {
+		return webhook.Delivery{}, fmt.Errorf("webhook delivery log is not enabled")
+	}
+	original, err := h.deliveryLog.Get(id)
+	if err != nil {
+		return webhook.Delivery{}, err
+	}
+	if original.Payload == nil {
+		return webhook.Delivery{}, fmt.Errorf("delivery %s has no payload to replay", id)
+	}
+
+	delivery := h.process(original.Payload, dryRun)
+	delivery.ReplayOf = original.ID
+	h.record(delivery)
+
+	h.logger.Info("webhook replayed",
+		slog.String("id", delivery.ID),
+		slog.String("replay_of", original.ID),
+		slog.Bool("dry_run", dryRun),
+		slog.Int("invalidated", delivery.Invalidated),
+	)
+	return delivery, nil
+}
+
+// ListDeliveries serves the delivery log as JSON, newest first.
+// The optional "limit" query parameter caps the number of entries.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if h.deliveryLog == nil {
+		writeWebhookError(w, http.StatusNotFound, fmt.Errorf("webhook delivery log is not enabled"))
+		return
+	}
+	deliveries, err := h.deliveryLog.List()
+	if err != nil {
+		writeWebhookError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(deliveries) {
+		deliveries = deliveries[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayDelivery re-runs the delivery named by the "id" query parameter.
+// With "dryRun=true" it only reports the pages that would be invalidated.
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeWebhookError(w, http.StatusBadRequest, fmt.Errorf("missing delivery id"))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	delivery, err := h.Replay(id, dryRun)
+	if errors.Is(err, webhook.ErrNotFound) {
+		writeWebhookError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeWebhookError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// writeWebhookError writes a JSON error response.
+func writeWebhookError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
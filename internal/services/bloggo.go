@@ -2,13 +2,19 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"statigo/framework/client"
+	fwctx "statigo/framework/context"
+	apperrors "statigo/framework/errors"
 )
 
 // BloggoTime handles the non-standard datetime format from the Bloggo API ("2025-10-12 13:15:38").
@@ -35,8 +41,9 @@ func (bt *BloggoTime) UnmarshalJSON(b []byte) error {
 
 // BloggoService communicates with the Bloggo headless CMS API.
 type BloggoService struct {
-	client *client.Client
-	logger *slog.Logger
+	client   *client.Client
+	logger   *slog.Logger
+	snapshot *Snapshot
 }
 
 // NewBloggoService creates a new Bloggo API service.
@@ -47,6 +54,207 @@ func NewBloggoService(c *client.Client, logger *slog.Logger) *BloggoService {
 	}
 }
 
+// SetSnapshot attaches a snapshot that records successful responses and
+// serves them while the API is unavailable.
+func (s *BloggoService) SetSnapshot(snapshot *Snapshot) {
+	s.snapshot = snapshot
+}
+
+// get fetches path into result. Errors wrap ErrNotFound, ErrBadRequest or
+// ErrUnavailable, except for invalid responses. When the API is unavailable, the response recorded in the snapshot is
+// used instead and the request is marked degraded.
+func (s *BloggoService) get(ctx context.Context, path string, result any) error {
+	if s.snapshot == nil || !snapshotted(path) {
+		if err := s.client.Get(ctx, path, result); err != nil {
+			return classify(ctx, err)
+		}
+		return nil
+	}
+
+	body, err := s.fetch(ctx, path)
+	if err == nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return classify(ctx, apperrors.NewParseError(err, "failed to decode response of "+path))
+		}
+		return nil
+	}
+	if err = classify(ctx, err); !errors.Is(err, ErrUnavailable) {
+		return err
+	}
+
+	fetchedAt, found, loadErr := s.snapshot.Load(path, result)
+	if loadErr != nil {
+		s.logger.Warn("Failed to read Bloggo snapshot", "path", path, "error", loadErr)
+		return err
+	}
+	if !found {
+		return err
+	}
+
+	fwctx.MarkDegraded(ctx, "snapshot")
+	s.logger.Warn("Serving Bloggo response from snapshot",
+		"path", path,
+		"age", time.Since(fetchedAt).Round(time.Second),
+		"error", err,
+	)
+	return nil
+}
+
+// maxSnapshotPage is the last page of the post list kept in the snapshot.
+const maxSnapshotPage = 100
+
+// snapshotted reports whether the response of path is kept in the snapshot:
+// posts, taxonomies and key-values, and the first pages of the unfiltered
+// post list. Filtered and searched lists take their values from visitors, so
+// recording them would let anyone add snapshot entries without bound.
+func snapshotted(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	if u.Path != "/api/posts" {
+		return true
+	}
+
+	query := u.Query()
+	for key := range query {
+		if key != "page" && key != "limit" {
+			return false
+		}
+	}
+	if page := query.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		return err == nil && n <= maxSnapshotPage
+	}
+	return true
+}
+
+// fetch requests path from the API and records the response in the snapshot.
+func (s *BloggoService) fetch(ctx context.Context, path string) (json.RawMessage, error) {
+	var body json.RawMessage
+	if err := s.client.Get(ctx, path, &body); err != nil {
+		return nil, err
+	}
+	if err := s.snapshot.Store(path, body); err != nil {
+		s.logger.Warn("Failed to update Bloggo snapshot", "path", path, "error", err)
+	}
+	return body, nil
+}
+
+// classify wraps a client error in ErrNotFound when the API reports the
+// content missing, in ErrBadRequest when it refuses the request, and in
+// ErrUnavailable when it could not answer: transport errors, timeouts, 5xx
+// and 429 responses and an open circuit. Other errors, such as invalid
+// responses or the caller canceling ctx, are returned as is.
+func classify(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	if status, ok := apperrors.GetHTTPStatusCode(err); ok {
+		switch {
+		case status == http.StatusNotFound || status == http.StatusGone:
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		case status >= http.StatusInternalServerError || status == http.StatusTooManyRequests:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		default:
+			return fmt.Errorf("%w: %w", ErrBadRequest, err)
+		}
+	}
+	if apperrors.IsNetworkError(err) || apperrors.IsTimeoutError(err) || apperrors.IsCircuitOpenError(err) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// PullSnapshot refreshes every snapshot entry and adds the categories, tags,
+// authors and all posts, so the site can be served offline. Entries the API
+// no longer has, or that are no longer kept, are removed. It returns the
+// number of responses stored.
+func (s *BloggoService) PullSnapshot(ctx context.Context) (int, error) {
+	if s.snapshot == nil {
+		return 0, fmt.Errorf("no snapshot configured")
+	}
+
+	existing, err := s.snapshot.Paths()
+	if err != nil {
+		return 0, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range existing {
+		if !snapshotted(path) {
+			if err := s.snapshot.Delete(path); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	add("/api/categories")
+	add("/api/tags")
+	add("/api/authors")
+	add("/api/key-values?")
+
+	// Page through all posts so every post page is available offline
+	const limit = 100
+	stored, failed := 0, 0
+	fetched := make(map[string]bool)
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/api/posts?limit=%d&page=%d", limit, page)
+		body, err := s.fetch(ctx, path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list posts: %w", err)
+		}
+		fetched[path] = true
+		stored++
+
+		var resp PostsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return 0, fmt.Errorf("failed to decode posts: %w", err)
+		}
+		for _, post := range resp.Data {
+			add("/api/posts/" + post.Slug)
+		}
+		if len(resp.Data) == 0 || page*limit >= resp.Total {
+			break
+		}
+	}
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+		if fetched[path] {
+			continue
+		}
+		_, err := s.fetch(ctx, path)
+		switch {
+		case err == nil:
+			stored++
+		case errors.Is(classify(ctx, err), ErrNotFound):
+			if err := s.snapshot.Delete(path); err != nil {
+				return stored, err
+			}
+			s.logger.Info("Removed snapshot entry the API no longer has", "path", path)
+		default:
+			failed++
+			s.logger.Warn("Failed to refresh snapshot entry", "path", path, "error", err)
+		}
+	}
+
+	if failed > 0 {
+		return stored, fmt.Errorf("failed to refresh %d of %d snapshot entries", failed, len(paths))
+	}
+	return stored, nil
+}
+
 // --- Response types ---
 
 type PostsResponse struct {
@@ -183,7 +391,7 @@ func (s *BloggoService) ListPosts(ctx context.Context, params ListPostsParams) (
 	path := "/api/posts?" + query.Encode()
 
 	var resp PostsResponse
-	if err := s.client.Get(ctx, path, &resp); err != nil {
+	if err := s.get(ctx, path, &resp); err != nil {
 		s.logger.Error("failed to list posts", "error", err)
 		return nil, err
 	}
@@ -192,12 +400,14 @@ func (s *BloggoService) ListPosts(ctx context.Context, params ListPostsParams) (
 
 func (s *BloggoService) GetPost(ctx context.Context, slug string) (*PostDetail, error) {
 	var resp PostDetail
-	if err := s.client.Get(ctx, "/api/posts/"+slug, &resp); err != nil {
+	if err := s.get(ctx, "/api/posts/"+slug, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
+// TrackView records a view of a post. It is sent once, since a retried
+// view could be counted twice.
 func (s *BloggoService) TrackView(ctx context.Context, slug string, userAgent string) error {
 	body := TrackViewRequest{UserAgent: userAgent}
 	return s.client.Post(ctx, "/api/posts/"+slug+"/view", body, nil)
@@ -205,7 +415,7 @@ func (s *BloggoService) TrackView(ctx context.Context, slug string, userAgent st
 
 func (s *BloggoService) GetViewCounts(ctx context.Context) (map[string]int, error) {
 	var resp map[string]int
-	if err := s.client.Get(ctx, "/api/posts/views", &resp); err != nil {
+	if err := s.get(ctx, "/api/posts/views", &resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
@@ -213,7 +423,7 @@ func (s *BloggoService) GetViewCounts(ctx context.Context) (map[string]int, erro
 
 func (s *BloggoService) ListCategories(ctx context.Context) ([]CategoryDetail, error) {
 	var resp CategoriesResponse
-	if err := s.client.Get(ctx, "/api/categories", &resp); err != nil {
+	if err := s.get(ctx, "/api/categories", &resp); err != nil {
 		return nil, err
 	}
 	return resp.Categories, nil
@@ -221,7 +431,7 @@ func (s *BloggoService) ListCategories(ctx context.Context) ([]CategoryDetail, e
 
 func (s *BloggoService) GetCategory(ctx context.Context, slug string) (*CategoryDetail, error) {
 	var resp CategoryDetail
-	if err := s.client.Get(ctx, "/api/categories/"+slug, &resp); err != nil {
+	if err := s.get(ctx, "/api/categories/"+slug, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -229,7 +439,7 @@ func (s *BloggoService) GetCategory(ctx context.Context, slug string) (*Category
 
 func (s *BloggoService) ListTags(ctx context.Context) ([]TagDetail, error) {
 	var resp TagsResponse
-	if err := s.client.Get(ctx, "/api/tags", &resp); err != nil {
+	if err := s.get(ctx, "/api/tags", &resp); err != nil {
 		return nil, err
 	}
 	return resp.Tags, nil
@@ -237,7 +447,7 @@ func (s *BloggoService) ListTags(ctx context.Context) ([]TagDetail, error) {
 
 func (s *BloggoService) GetTag(ctx context.Context, slug string) (*TagDetail, error) {
 	var resp TagDetail
-	if err := s.client.Get(ctx, "/api/tags/"+slug, &resp); err != nil {
+	if err := s.get(ctx, "/api/tags/"+slug, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -245,7 +455,7 @@ func (s *BloggoService) GetTag(ctx context.Context, slug string) (*TagDetail, er
 
 func (s *BloggoService) ListAuthors(ctx context.Context) ([]AuthorDetail, error) {
 	var resp AuthorsResponse
-	if err := s.client.Get(ctx, "/api/authors", &resp); err != nil {
+	if err := s.get(ctx, "/api/authors", &resp); err != nil {
 		return nil, err
 	}
 	return resp.Authors, nil
@@ -253,7 +463,7 @@ func (s *BloggoService) ListAuthors(ctx context.Context) ([]AuthorDetail, error)
 
 func (s *BloggoService) GetAuthor(ctx context.Context, id int) (*AuthorDetail, error) {
 	var resp AuthorDetail
-	if err := s.client.Get(ctx, fmt.Sprintf("/api/authors/%d", id), &resp); err != nil {
+	if err := s.get(ctx, fmt.Sprintf("/api/authors/%d", id), &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -271,7 +481,7 @@ func (s *BloggoService) GetKeyValues(ctx context.Context, key, starting string)
 	path := "/api/key-values?" + query.Encode()
 
 	var resp []KeyValue
-	if err := s.client.Get(ctx, path, &resp); err != nil {
+	if err := s.get(ctx, path, &resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
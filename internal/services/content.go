@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// Errors returned by content sources. They wrap the underlying error, so
+// check them with errors.Is.
+var (
+	ErrNotFound    = errors.New("content not found")          // The requested content does not exist
+	ErrBadRequest  = errors.New("content request rejected")   // The source refused the request, e.g. for a malformed slug
+	ErrUnavailable = errors.New("content source unavailable") // The source could not be reached or failed
+)
+
+// ContentSource provides the posts, categories, tags and authors rendered
+// by the site. BloggoService reads them from the Bloggo API and
+// MarkdownSource from a directory of Markdown files.
+type ContentSource interface {
+	ListPosts(ctx context.Context, params ListPostsParams) (*PostsResponse, error)
+	GetPost(ctx context.Context, slug string) (*PostDetail, error)
+	ListCategories(ctx context.Context) ([]CategoryDetail, error)
+	ListTags(ctx context.Context) ([]TagDetail, error)
+	ListAuthors(ctx context.Context) ([]AuthorDetail, error)
+	GetKeyValues(ctx context.Context, key, starting string) ([]KeyValue, error)
+}
+
+// ViewCounter is implemented by content sources that count post views.
+type ViewCounter interface {
+	TrackView(ctx context.Context, slug string, userAgent string) error
+	GetViewCounts(ctx context.Context) (map[string]int, error)
+}
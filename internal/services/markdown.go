@@ -0,0 +1,548 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"statigo/framework/templates"
+)
+
+// defaultPostsLimit is the page size used when ListPostsParams.Limit is unset.
+const defaultPostsLimit = 10
+
+// MarkdownSource serves posts from a directory of Markdown files. Each file
+// starts with YAML front matter:
+//
+//	---
+//	title: Hello World
+//	slug: hello-world
+//	category: Announcements
+//	tags: [go, statigo]
+//	publishedAt: 2025-10-12
+//	cover: /images/hello.jpg
+//	---
+//
+// Categories, tags and authors are derived from the posts. Files changed on
+// disk are picked up on the next call, so the site can be edited in place.
+type MarkdownSource struct {
+	dir    string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	files map[string]markdownFile // Keyed by file path
+	posts []*PostDetail           // Published posts, newest first
+}
+
+// markdownFile is a parsed file and the stat it was parsed at.
+type markdownFile struct {
+	modTime time.Time
+	size    int64
+	post    *PostDetail // nil for drafts and invalid files
+}
+
+// NewMarkdownSource creates a content source reading posts from dir.
+func NewMarkdownSource(dir string, logger *slog.Logger) (*MarkdownSource, error) {
+	s := &MarkdownSource{
+		dir:    dir,
+		logger: logger,
+		files:  make(map[string]markdownFile),
+	}
+	if _, err := s.load(); err != nil {
+		return nil, err
+	}
+	logger.Info("Loaded markdown content", "dir", dir, "posts", len(s.posts))
+	return s, nil
+}
+
+func (s *MarkdownSource) ListPosts(ctx context.Context, params ListPostsParams) (*PostsResponse, error) {
+	posts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	search := strings.ToLower(params.Search)
+	var matched []PostSummary
+	for _, post := range posts {
+		if params.Category != "" && post.Category.Slug != params.Category {
+			continue
+		}
+		if params.Tag != "" && !hasTag(post.Tags, params.Tag) {
+			continue
+		}
+		if params.Author != "" && params.Author != strconv.Itoa(post.Author.ID) && params.Author != templates.Slugify(post.Author.Name) {
+			continue
+		}
+		if search != "" && !matchesSearch(post, search) {
+			continue
+		}
+		matched = append(matched, summarize(post))
+	}
+
+	page := max(params.Page, 1)
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultPostsLimit
+	}
+	start := min((page-1)*limit, len(matched))
+	end := min(start+limit, len(matched))
+
+	return &PostsResponse{
+		Data:  matched[start:end],
+		Page:  page,
+		Take:  limit,
+		Total: len(matched),
+	}, nil
+}
+
+func (s *MarkdownSource) GetPost(ctx context.Context, slug string) (*PostDetail, error) {
+	posts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if post.Slug == slug {
+			detail := *post
+			return &detail, nil
+		}
+	}
+	return nil, fmt.Errorf("post %q: %w", slug, ErrNotFound)
+}
+
+func (s *MarkdownSource) ListCategories(ctx context.Context) ([]CategoryDetail, error) {
+	posts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var categories []CategoryDetail
+	for _, post := range posts {
+		if post.Category.Slug == "" {
+			continue
+		}
+		i, ok := index[post.Category.Slug]
+		if !ok {
+			i = len(categories)
+			index[post.Category.Slug] = i
+			categories = append(categories, CategoryDetail{Slug: post.Category.Slug, Name: post.Category.Name})
+		}
+		categories[i].PostCount++
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (s *MarkdownSource) ListTags(ctx context.Context) ([]TagDetail, error) {
+	posts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var tags []TagDetail
+	for _, post := range posts {
+		for _, tag := range post.Tags {
+			i, ok := index[tag.Slug]
+			if !ok {
+				i = len(tags)
+				index[tag.Slug] = i
+				tags = append(tags, TagDetail{Slug: tag.Slug, Name: tag.Name})
+			}
+			tags[i].PostCount++
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *MarkdownSource) ListAuthors(ctx context.Context) ([]AuthorDetail, error) {
+	posts, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int]int)
+	var authors []AuthorDetail
+	for _, post := range posts {
+		if post.Author.Name == "" {
+			continue
+		}
+		i, ok := index[post.Author.ID]
+		if !ok {
+			i = len(authors)
+			index[post.Author.ID] = i
+			authors = append(authors, AuthorDetail{ID: post.Author.ID, Name: post.Author.Name})
+		}
+		authors[i].PublishedPostCount++
+		// Posts are newest first, so the last one seen is the earliest
+		authors[i].MemberSince = post.PublishedAt
+	}
+	sort.Slice(authors, func(i, j int) bool { return authors[i].ID < authors[j].ID })
+	return authors, nil
+}
+
+// GetKeyValues returns no values; key-values are a Bloggo feature.
+func (s *MarkdownSource) GetKeyValues(ctx context.Context, key, starting string) ([]KeyValue, error) {
+	return []KeyValue{}, nil
+}
+
+// load rereads files added, changed or removed since the last call and
+// returns the published posts.
+func (s *MarkdownSource) load() ([]*PostDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	changed := false
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		seen[path] = true
+		if file, ok := s.files[path]; ok && file.modTime.Equal(info.ModTime()) && file.size == info.Size() {
+			return nil
+		}
+
+		post, err := parseMarkdownPost(path, info.ModTime())
+		if err != nil {
+			s.logger.Warn("Skipping invalid markdown post", "file", path, "error", err)
+		}
+		s.files[path] = markdownFile{modTime: info.ModTime(), size: info.Size(), post: post}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read content directory: %w", ErrUnavailable, err)
+	}
+
+	for path := range s.files {
+		if !seen[path] {
+			delete(s.files, path)
+			changed = true
+		}
+	}
+
+	if changed {
+		s.posts = s.collect()
+	}
+	return s.posts, nil
+}
+
+// collect builds the sorted post list from the parsed files. Posts are
+// copied so lists handed out earlier are never modified.
+func (s *MarkdownSource) collect() []*PostDetail {
+	paths := make([]string, 0, len(s.files))
+	for path, file := range s.files {
+		if file.post != nil {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var posts []*PostDetail
+	slugs := make(map[string]string)
+	authorNames := make(map[string]bool)
+	for _, path := range paths {
+		post := *s.files[path].post
+		if other, ok := slugs[post.Slug]; ok {
+			s.logger.Warn("Skipping markdown post with duplicate slug", "file", path, "slug", post.Slug, "other", other)
+			continue
+		}
+		slugs[post.Slug] = path
+		authorNames[post.Author.Name] = true
+		posts = append(posts, &post)
+	}
+
+	// Number authors by name so IDs are stable across reloads
+	names := make([]string, 0, len(authorNames))
+	for name := range authorNames {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	authorIDs := make(map[string]int, len(names))
+	for i, name := range names {
+		authorIDs[name] = i + 1
+	}
+	for _, post := range posts {
+		post.Author.ID = authorIDs[post.Author.Name]
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].PublishedAt.After(posts[j].PublishedAt.Time)
+	})
+	return posts
+}
+
+// parseMarkdownPost reads a post file. Drafts return a nil post.
+func parseMarkdownPost(path string, modTime time.Time) (*PostDetail, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	meta, body, err := parseFrontMatter(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if meta.bool("draft") {
+		return nil, nil
+	}
+
+	slug := meta.string("slug")
+	if slug == "" {
+		slug = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	title := meta.string("title")
+	if title == "" {
+		title = slug
+	}
+
+	publishedAt := modTime
+	if value := meta.string("publishedAt"); value != "" {
+		if publishedAt, err = parseContentTime(value); err != nil {
+			return nil, fmt.Errorf("invalid publishedAt: %w", err)
+		}
+	}
+	updatedAt := publishedAt
+	if value := meta.string("updatedAt"); value != "" {
+		if updatedAt, err = parseContentTime(value); err != nil {
+			return nil, fmt.Errorf("invalid updatedAt: %w", err)
+		}
+	}
+
+	post := &PostDetail{
+		Slug:        slug,
+		Title:       title,
+		Content:     body,
+		Description: optional(meta.string("description")),
+		Spot:        optional(meta.string("spot")),
+		CoverImage:  optional(meta.string("cover")),
+		ReadTime:    max(len(strings.Fields(body))/200, 1),
+		PublishedAt: BloggoTime{publishedAt},
+		UpdatedAt:   BloggoTime{updatedAt},
+		Author:      Author{Name: meta.string("author")},
+	}
+	if name := meta.string("category"); name != "" {
+		post.Category = Category{Slug: templates.Slugify(name), Name: name}
+	}
+	for _, name := range meta.list("tags") {
+		post.Tags = append(post.Tags, TagShort{Slug: templates.Slugify(name), Name: name})
+	}
+	return post, nil
+}
+
+// contentTimeLayouts are the date formats accepted in front matter.
+var contentTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseContentTime(value string) (time.Time, error) {
+	for _, layout := range contentTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date", value)
+}
+
+// frontMatter holds front matter values, each a string or a []string.
+type frontMatter map[string]any
+
+func (m frontMatter) string(key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func (m frontMatter) list(key string) []string {
+	switch value := m[key].(type) {
+	case []string:
+		return value
+	case string:
+		if value != "" {
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+func (m frontMatter) bool(key string) bool {
+	switch strings.ToLower(m.string(key)) {
+	case "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// parseFrontMatter splits a document into its front matter and body. The
+// front matter supports the subset of YAML posts need: scalars, quoted
+// strings, comments, and flow ([a, b]) or block ("- a") lists.
+func parseFrontMatter(content string) (frontMatter, string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	meta := frontMatter{}
+	lines := strings.Split(content, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return meta, content, nil
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, "", fmt.Errorf("front matter is not closed with ---")
+	}
+
+	listKey := ""
+	for i, line := range lines[1:end] {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			if listKey == "" {
+				return nil, "", fmt.Errorf("front matter line %d: list item without a key", i+2)
+			}
+			item := yamlScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")))
+			meta[listKey] = append(meta[listKey].([]string), item)
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || key == "" {
+			return nil, "", fmt.Errorf("front matter line %d: expected \"key: value\"", i+2)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		listKey = ""
+
+		switch {
+		case value == "":
+			meta[key] = []string{}
+			listKey = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := []string{}
+			for _, item := range splitFlowList(value[1 : len(value)-1]) {
+				if item = yamlScalar(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			meta[key] = items
+		default:
+			meta[key] = yamlScalar(value)
+		}
+	}
+
+	body := strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+	return meta, body, nil
+}
+
+// splitFlowList splits the items of a flow list at commas outside quotes.
+func splitFlowList(list string) []string {
+	var items []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case quote == '"' && c == '\\':
+			i++ // Skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, list[start:])
+}
+
+// yamlScalar unquotes a quoted scalar or strips a trailing comment from a
+// plain one.
+func yamlScalar(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted
+			}
+			return value[1 : len(value)-1]
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+func summarize(post *PostDetail) PostSummary {
+	return PostSummary{
+		Slug:        post.Slug,
+		Title:       post.Title,
+		Description: post.Description,
+		Spot:        post.Spot,
+		CoverImage:  post.CoverImage,
+		ReadCount:   post.ReadCount,
+		ReadTime:    post.ReadTime,
+		PublishedAt: post.PublishedAt,
+		Author:      post.Author,
+		Category:    post.Category,
+		Tags:        post.Tags,
+	}
+}
+
+func hasTag(tags []TagShort, slug string) bool {
+	for _, tag := range tags {
+		if tag.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSearch(post *PostDetail, search string) bool {
+	if strings.Contains(strings.ToLower(post.Title), search) ||
+		strings.Contains(strings.ToLower(post.Content), search) {
+		return true
+	}
+	return post.Description != nil && strings.Contains(strings.ToLower(*post.Description), search)
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
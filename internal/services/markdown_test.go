@@ -0,0 +1,118 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantMeta frontMatter
+		wantBody string
+		wantErr  bool
+	}{
+		{
+			name:     "no front matter",
+			content:  "# Title\n\nBody",
+			wantMeta: frontMatter{},
+			wantBody: "# Title\n\nBody",
+		},
+		{
+			name:     "scalars",
+			content:  "---\ntitle: Hello\ndraft: true\n---\nBody",
+			wantMeta: frontMatter{"title": "Hello", "draft": "true"},
+			wantBody: "Body",
+		},
+		{
+			name:     "quoted strings",
+			content:  "---\ntitle: \"Hello: \\\"World\\\" # not a comment\"\nspot: 'It''s here'\n---\nBody",
+			wantMeta: frontMatter{"title": `Hello: "World" # not a comment`, "spot": "It's here"},
+			wantBody: "Body",
+		},
+		{
+			name:     "comments",
+			content:  "---\n# A comment\ntitle: Hello # trailing\nurl: http://example.com/#anchor\n---\nBody",
+			wantMeta: frontMatter{"title": "Hello", "url": "http://example.com/#anchor"},
+			wantBody: "Body",
+		},
+		{
+			name:     "flow list",
+			content:  "---\ntags: [go, \"web, http\", '' , ]\n---\nBody",
+			wantMeta: frontMatter{"tags": []string{"go", "web, http"}},
+			wantBody: "Body",
+		},
+		{
+			name:     "block list",
+			content:  "---\ntags:\n  - go\n  - \"web\"\ntitle: Hello\n---\nBody",
+			wantMeta: frontMatter{"tags": []string{"go", "web"}, "title": "Hello"},
+			wantBody: "Body",
+		},
+		{
+			name:     "empty key is an empty list",
+			content:  "---\ntags:\ntitle: Hello\n---\nBody",
+			wantMeta: frontMatter{"tags": []string{}, "title": "Hello"},
+			wantBody: "Body",
+		},
+		{
+			name:     "CRLF and BOM",
+			content:  "\ufeff---\r\ntitle: Hello\r\n---\r\n\r\nBody\r\n",
+			wantMeta: frontMatter{"title": "Hello"},
+			wantBody: "Body\n",
+		},
+		{
+			name:     "delimiter lines with spaces",
+			content:  "--- \ntitle: Hello\n ---\nBody",
+			wantMeta: frontMatter{"title": "Hello"},
+			wantBody: "Body",
+		},
+		{
+			name:     "body keeps later delimiters",
+			content:  "---\ntitle: Hello\n---\nIntro\n---\nMore",
+			wantMeta: frontMatter{"title": "Hello"},
+			wantBody: "Intro\n---\nMore",
+		},
+		{
+			name:    "not closed",
+			content: "---\ntitle: Hello\nBody",
+			wantErr: true,
+		},
+		{
+			name:    "list item without a key",
+			content: "---\n- go\n---\nBody",
+			wantErr: true,
+		},
+		{
+			name:    "list item after a scalar",
+			content: "---\ntitle: Hello\n- go\n---\nBody",
+			wantErr: true,
+		},
+		{
+			name:    "line without a key",
+			content: "---\ntitle Hello\n---\nBody",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body, err := parseFrontMatter(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseFrontMatter() = %v, want error", meta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFrontMatter(): %v", err)
+			}
+			if !reflect.DeepEqual(meta, tt.wantMeta) {
+				t.Errorf("meta = %#v, want %#v", meta, tt.wantMeta)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
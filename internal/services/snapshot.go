@@ -0,0 +1,126 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot persists the last successful API response for each request path,
+// one JSON file per path, so content can still be served while the API is
+// unreachable.
+type Snapshot struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// snapshotEntry is the on-disk form of a snapshot entry.
+type snapshotEntry struct {
+	Path      string          `json:"path"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// OpenSnapshot opens the snapshot stored in dir, creating the directory.
+func OpenSnapshot(dir string, logger *slog.Logger) (*Snapshot, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return &Snapshot{dir: dir, logger: logger}, nil
+}
+
+// Store records body as the latest response for path.
+func (s *Snapshot) Store(path string, body json.RawMessage) error {
+	data, err := json.Marshal(snapshotEntry{Path: path, FetchedAt: time.Now(), Body: body})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot entry: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.file(path)); err != nil {
+		return fmt.Errorf("failed to write snapshot entry: %w", err)
+	}
+	return nil
+}
+
+// Load decodes the latest response for path into result. It reports when the
+// response was fetched, and false if the snapshot has no entry for path.
+func (s *Snapshot) Load(path string, result any) (time.Time, bool, error) {
+	data, err := os.ReadFile(s.file(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read snapshot entry: %w", err)
+	}
+
+	var entry snapshotEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to decode snapshot entry: %w", err)
+	}
+	if err := json.Unmarshal(entry.Body, result); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to decode snapshot entry: %w", err)
+	}
+	return entry.FetchedAt, true, nil
+}
+
+// Delete removes the entry for path.
+func (s *Snapshot) Delete(path string) error {
+	if err := os.Remove(s.file(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete snapshot entry: %w", err)
+	}
+	return nil
+}
+
+// Paths returns the request paths with an entry, sorted.
+func (s *Snapshot) Paths() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var paths []string
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot entry: %w", err)
+		}
+		var entry snapshotEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Path == "" {
+			s.logger.Warn("Ignoring invalid snapshot entry", "file", file.Name())
+			continue
+		}
+		paths = append(paths, entry.Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// file returns the file holding the entry for path.
+func (s *Snapshot) file(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
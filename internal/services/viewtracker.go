@@ -1,10 +1,11 @@
 package services
 
 import (
-	"net"
 	"net/http"
 	"sync"
 	"time"
+
+	"statigo/framework/security"
 )
 
 // ViewTracker tracks blog post views with IP-based rate limiting.
@@ -42,7 +43,7 @@ const cleanupInterval = 5 * time.Minute
 // ShouldTrackView returns true if the view should be tracked (not within cooldown period).
 func (vt *ViewTracker) ShouldTrackView(r *http.Request, slug string) bool {
 	// Get client IP
-	ip := getClientIP(r)
+	ip := security.GetClientIP(r)
 	if ip == "" {
 		vt.logger.Debug("no client IP found, skipping view tracking")
 		return false
@@ -72,35 +73,6 @@ func (vt *ViewTracker) ShouldTrackView(r *http.Request, slug string) bool {
 	return true
 }
 
-// getClientIP extracts the client IP from the request, checking headers for proxies.
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (for proxies/load balancers)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one (original client)
-		if idx := len(xff); idx > 0 {
-			if ip, _, err := net.SplitHostPort(xff); err == nil {
-				return ip
-			}
-			return xff
-		}
-	}
-
-	// Check X-Real-IP header (common with nginx)
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		if ip, _, err := net.SplitHostPort(xri); err == nil {
-			return ip
-		}
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
 // startCleanup begins the periodic cleanup of old entries.
 func (vt *ViewTracker) startCleanup() {
 	vt.cleanupTimer = time.AfterFunc(cleanupInterval, func() {
@@ -10,28 +10,19 @@ import (
 	"os"
 	"os/signal"
 	"path"
-	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
-	chiMiddleware "github.com/go-chi/chi/middleware"
 	"github.com/joho/godotenv"
 
-	"statigo/framework/cache"
 	"statigo/framework/cli"
-	"statigo/framework/client"
-	"statigo/framework/dictionary"
-	"statigo/framework/health"
 	fwlogger "statigo/framework/logger"
 	"statigo/framework/middleware"
-	"statigo/framework/router"
 	"statigo/framework/security"
-	"statigo/framework/templates"
 	"statigo/framework/utils"
-	"statigo/internal/handlers"
-	"statigo/internal/services"
+	"statigo/framework/webhook"
 )
 
 func main() {
@@ -45,274 +36,208 @@ func main() {
 	if logLevel == "" {
 		logLevel = "INFO"
 	}
-	appLogger := fwlogger.InitLogger(logLevel)
-
-	// Get embedded filesystems
-	translationsFS := GetTranslationsFS()
-	templatesFS := GetTemplatesFS()
-	configFS := GetConfigFS()
-	staticFS := GetStaticFS()
-
-	// Initialize dictionary
-	dict, err := dictionary.New(translationsFS, "en")
-	if err != nil {
-		appLogger.Error("Failed to initialize dictionary", "error", err)
-		os.Exit(1)
-	}
-
-	// Initialize routing system
-	routeRegistry := router.NewRegistry()
-
-	// Initialize SEO helpers
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
-	}
-	seoHelpers := router.NewSEOHelpers(routeRegistry, baseURL)
-	routerSEOFuncs := seoHelpers.ToTemplateFunctions()
-
-	// Convert to templates.SEOFunctions (same structure, different package)
-	seoFuncs := &templates.SEOFunctions{
-		CanonicalURL:   routerSEOFuncs.CanonicalURL,
-		AlternateLinks: routerSEOFuncs.AlternateLinks,
-		AlternateURLs:  routerSEOFuncs.AlternateURLs,
-		LocalePath:     routerSEOFuncs.LocalePath,
+	// Commands log to stderr, keeping stdout for their output (e.g. JSON)
+	a := newApp(fwlogger.NewLogger(os.Stderr, logLevel))
+
+	// CLI commands (prerender, clear-cache, etc.). Registration alone decides
+	// whether the arguments name a command; otherwise the server is started.
+	cliApp := newCLI(a)
+	if cliApp.IsCommand(os.Args[1:]) {
+		if code := cliApp.Run(os.Args[1:]); code != cli.ExitOK {
+			os.Exit(code)
+		}
+		return
 	}
 
-	// Initialize template renderer
-	renderer, err := templates.NewRenderer(templatesFS, dict, seoFuncs, appLogger)
-	if err != nil {
-		appLogger.Error("Failed to initialize template renderer", "error", err)
+	a.logger = fwlogger.InitLogger(logLevel)
+	if err := serve(a); err != nil {
+		a.logger.Error("Server error", "error", err)
 		os.Exit(1)
 	}
+}
 
-	// Development mode check
-	devMode := os.Getenv("DEV_MODE") == "true"
-
-	// Initialize cache manager (skip in dev mode)
-	cacheDir := os.Getenv("CACHE_DIR")
-	if cacheDir == "" {
-		workDir, _ := os.Getwd()
-		cacheDir = filepath.Join(workDir, "data", "cache")
-	}
-	var cacheManager *cache.Manager
-	if !devMode {
-		if err := os.MkdirAll(cacheDir, 0755); err != nil {
-			appLogger.Error("Failed to create cache directory", "error", err)
-			os.Exit(1)
-		}
-		var err error
-		cacheManager, err = cache.NewManager(cacheDir, appLogger)
-		if err != nil {
-			appLogger.Error("Failed to initialize cache manager", "error", err)
-			os.Exit(1)
+// newCLI registers the commands. Each one builds the parts of the
+// application it needs only when it runs.
+func newCLI(a *app) *cli.CLI {
+	// Prerendering fills the disk cache; exporting renders every page fresh
+	// and leaves the cache, which a running server may own, untouched
+	renderSite := func(diskCache bool) func() (*cli.Site, error) {
+		return func() (*cli.Site, error) {
+			s, err := a.buildSite()
+			if err != nil {
+				return nil, err
+			}
+			r := s.router(routerOptions{diskCache: diskCache})
+			if diskCache && s.cacheManager != nil {
+				s.cacheManager.SetRouter(r)
+			}
+			return &cli.Site{
+				Router:       r,
+				CacheManager: s.cacheManager,
+				Routes:       s.routes,
+				Redirects:    s.redirects,
+				PathExpander: s.expandPosts,
+			}, nil
 		}
-		appLogger.Info("Cache manager initialized", "dir", cacheDir)
-	} else {
-		appLogger.Info("Disk cache disabled in dev mode")
 	}
 
-	// Initialize Bloggo API client
-	bloggoAPIURL := utils.GetEnvString("BLOGGO_API_URL", "http://debian:8723")
-	bloggoAPIKey := utils.GetEnvString("BLOGGO_API_KEY", "")
-	bloggoClient := client.New(client.Config{
-		BaseURL:         bloggoAPIURL,
-		Timeout:         time.Duration(utils.GetEnvInt("HTTP_TIMEOUT", 30)) * time.Second,
-		ConnectTimeout:  time.Duration(utils.GetEnvInt("HTTP_CONNECT_TIMEOUT", 10)) * time.Second,
-		TLSTimeout:      time.Duration(utils.GetEnvInt("HTTP_TLS_TIMEOUT", 10)) * time.Second,
-		IdleConnTimeout: time.Duration(utils.GetEnvInt("HTTP_IDLE_TIMEOUT", 90)) * time.Second,
-		MaxRetries:      utils.GetEnvInt("HTTP_MAX_RETRIES", 3),
-		RetryWaitMin:    time.Duration(utils.GetEnvInt("HTTP_RETRY_BASE_DELAY", 500)) * time.Millisecond,
-		RetryWaitMax:    30 * time.Second,
-		UserAgent:       "Statigo/1.0",
-		Headers: map[string]string{
-			"x-trusted-frontend": bloggoAPIKey,
+	cliApp := cli.New()
+	cliApp.Register(cli.NewPrerenderCommand(cli.PrerenderCommandConfig{
+		ConfigFS:   GetConfigFS(),
+		RoutesFile: "routes.json",
+		Languages:  []string{"en"},
+		Site:       renderSite(true),
+		Logger:     a.logger,
+	}))
+	cliApp.Register(cli.NewClearCacheCommand(cli.ClearCacheCommandConfig{
+		CacheDir: a.cacheDir,
+		Logger:   a.logger,
+	}))
+	cliApp.Register(cli.NewExportCommand(cli.ExportCommandConfig{
+		Site:     renderSite(false),
+		StaticFS: GetStaticFS(),
+		Files:    []string{"/sitemap.xml", "/rss"},
+		Logger:   a.logger,
+	}))
+	cliApp.Register(cli.NewBansCommand(cli.BansCommandConfig{
+		BanListFile: a.banListFile(),
+		LockFile:    a.banListFile() + ".lock",
+		SocketPath:  a.adminSocket(),
+		Allowlist:   banAllowlist(),
+		Logger:      a.logger,
+	}))
+	cliApp.Register(cli.NewWebhooksCommand(cli.WebhooksCommandConfig{
+		OpenLog:    a.openWebhookLog,
+		SocketPath: a.adminSocket(),
+		Replay: func(id string, dryRun bool) (webhook.Delivery, error) {
+			s, err := a.buildSite()
+			if err != nil {
+				return webhook.Delivery{}, err
+			}
+			if s.cacheManager != nil {
+				s.cacheManager.SetRouter(s.router(routerOptions{diskCache: true}))
+			}
+			return s.webhooks.Replay(id, dryRun)
 		},
-	}, appLogger)
-	bloggoService := services.NewBloggoService(bloggoClient, appLogger)
-	viewTracker := services.NewViewTracker(appLogger)
-	viewsHandler := handlers.NewViewsHandler(bloggoService, 10*time.Minute)
-
-	// Initialize webhook handler
-	webhookSecret := utils.GetEnvString("WEBHOOK_SECRET", "")
-	webhookHandler := handlers.NewWebhookHandler(cacheManager, viewsHandler, appLogger)
-
-	// Initialize handlers
-	indexHandler := handlers.NewIndexHandler(renderer)
-	aboutHandler := handlers.NewAboutHandler(renderer)
-	blogsHandler := handlers.NewBlogsHandler(renderer, bloggoService, bloggoAPIURL)
-	blogPostHandler := handlers.NewBlogPostHandler(renderer, bloggoService, bloggoAPIURL, viewTracker, viewsHandler)
-	feedHandler := handlers.NewFeedHandler(bloggoService, bloggoAPIURL, baseURL)
-	sitemapHandler := handlers.NewSitemapHandler(bloggoService, baseURL)
-	notFoundHandler := handlers.NewNotFoundHandler(renderer)
-
-	// Create custom handlers map for route loader
-	customHandlers := map[string]http.HandlerFunc{
-		"index":    indexHandler.ServeHTTP,
-		"blogs":    blogsHandler.ServeHTTP,
-		"about":    aboutHandler.ServeHTTP,
-		"blogpost": blogPostHandler.ServeHTTP,
+	}))
+	if usesBloggo() {
+		cliApp.Register(cli.NewSnapshotCommand(cli.SnapshotCommandConfig{
+			Pull: func(ctx context.Context) (int, error) {
+				content, err := a.contentSource()
+				if err != nil {
+					return 0, err
+				}
+				return content.bloggo.PullSnapshot(ctx)
+			},
+			Logger: a.logger,
+		}))
 	}
+	return cliApp
+}
 
-	// Load routes from JSON configuration
-	if err := router.LoadRoutesFromJSON(
-		configFS,
-		"routes.json",
-		routeRegistry,
-		renderer,
-		customHandlers,
-		appLogger,
-	); err != nil {
-		appLogger.Error("Failed to load routes", "error", err)
-		os.Exit(1)
+// serve sets up the server-only parts (ban list, threat scoring, honeypots,
+// rate limiting, webhooks and the admin socket) and runs the server until
+// it is shut down.
+func serve(a *app) error {
+	s, err := a.buildSite()
+	if err != nil {
+		return err
 	}
+	appLogger := a.logger
+	configFS := GetConfigFS()
 
-	// Initialize IP ban list
-	banListFile := filepath.Join(filepath.Dir(cacheDir), "banned-ips.json")
-	if err := os.MkdirAll(filepath.Dir(banListFile), 0755); err != nil {
-		appLogger.Error("Failed to create data directory", "error", err)
-		os.Exit(1)
+	// Own the ban list for as long as the server runs, so the bans command
+	// can't edit the file underneath it
+	banListFile := a.banListFile()
+	unlock, err := security.LockFile(banListFile + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock ban list: %w", err)
 	}
+	defer unlock()
+
+	// Initialize IP ban list
 	ipBanList, err := security.NewIPBanList(banListFile, appLogger)
 	if err != nil {
-		appLogger.Error("Failed to initialize IP ban list", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize IP ban list: %w", err)
+	}
+	defer ipBanList.Stop()
+	if err := ipBanList.SetAllowlist(banAllowlist()); err != nil {
+		return fmt.Errorf("failed to configure ban allowlist: %w", err)
 	}
-
-	// Initialize health check handler
-	healthHandler := health.NewHandler(5 * time.Second)
-
-	// Create router
-	r := chi.NewRouter()
 
 	// Rate limiting configuration
 	rateLimitRPS := utils.GetEnvInt("RATE_LIMIT_RPS", 10)
 	rateLimitBurst := utils.GetEnvInt("RATE_LIMIT_BURST", 20)
 
-	// Honeypot paths for bot detection
-	honeypotPaths := []string{
-		"/admin", "/wp-admin", "/wp-login.php", "/.env", "/.git/config",
-		"/phpMyAdmin", "/administrator", "/cpanel",
+	// Threat scoring (honeypots, 404 bursts, rate-limit violations, attack tools)
+	threatConfig, err := security.LoadThreatConfig(configFS, "threats.json")
+	if err != nil {
+		return fmt.Errorf("failed to load threat config: %w", err)
 	}
-
-	// Load redirects
-	redirectRegistry, err := middleware.LoadRedirectsFromJSON(configFS, "redirects.json", appLogger)
+	threatScorer, err := security.NewThreatScorer(threatConfig, ipBanList, appLogger)
 	if err != nil {
-		appLogger.Error("Failed to load redirects", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize threat scoring: %w", err)
 	}
+	defer threatScorer.Stop()
 
-	// Apply middleware
-	r.Use(middleware.StructuredLogger(appLogger))
-	r.Use(chiMiddleware.Recoverer)
-	r.Use(middleware.IPBanMiddleware(ipBanList, appLogger))
-	r.Use(middleware.HoneypotMiddleware(ipBanList, honeypotPaths, appLogger))
-	r.Use(middleware.RateLimiter(middleware.RateLimiterConfig{
-		RPS:   rateLimitRPS,
-		Burst: rateLimitBurst,
-	}))
-	r.Use(middleware.RedirectMiddleware(redirectRegistry, appLogger))
-	r.Use(middleware.Compression())
-	r.Use(middleware.SecurityHeadersSimple())
-	r.Use(middleware.CachingHeaders(devMode))
-
-	// Static file serving middleware
-	minifier := utils.NewMinifier()
-	httpFS := http.FS(staticFS)
-	r.Use(staticFileMiddleware(staticFS, httpFS, minifier))
-
-	// Language middleware
-	langConfig := middleware.LanguageConfig{
-		SkipPaths:    []string{"/robots.txt", "/sitemap.xml", "/favicon.ico"},
-		SkipPrefixes: []string{"/health/", "/static/", "/styles/", "/scripts/"},
+	// Load honeypot rules
+	honeypotRegistry, err := middleware.LoadHoneypotsFromJSON(configFS, "honeypots.json", appLogger)
+	if err != nil {
+		return fmt.Errorf("failed to load honeypots: %w", err)
 	}
-	r.Use(middleware.Language(dict, langConfig))
 
-	// Canonical path middleware
-	r.Use(router.CanonicalPathMiddleware(routeRegistry))
-
-	// View tracking middleware (runs before cache, so views are counted on cache hits too)
-	r.Use(blogPostHandler.ViewTrackingMiddleware)
+	r := s.router(routerOptions{
+		guards: []func(http.Handler) http.Handler{
+			middleware.IPBanMiddleware(ipBanList, appLogger),
+			middleware.HoneypotMiddleware(honeypotRegistry, threatScorer, ipBanList, appLogger),
+			middleware.ThreatMiddleware(threatScorer, appLogger),
+			middleware.RateLimiter(middleware.RateLimiterConfig{
+				RPS:         rateLimitRPS,
+				Burst:       rateLimitBurst,
+				MaxClients:  utils.GetEnvInt("RATE_LIMIT_MAX_CLIENTS", 10000),
+				IdleTimeout: time.Duration(utils.GetEnvInt("RATE_LIMIT_IDLE_MINUTES", 10)) * time.Minute,
+				RouteLimit: func(path string) (string, middleware.RouteRateLimit, bool) {
+					route := s.routes.Lookup(path)
+					if route == nil || route.RateLimit == nil {
+						return "", middleware.RouteRateLimit{}, false
+					}
+					return route.Path, middleware.RouteRateLimit{
+						RPS:   route.RateLimit.RPS,
+						Burst: route.RateLimit.Burst,
+					}, true
+				},
+			}),
+		},
+		diskCache: true,
+	})
 
-	// Cache middleware (skip disk cache in dev mode)
-	if !devMode {
-		r.Use(middleware.CacheMiddleware(cacheManager, appLogger))
+	// Webhook endpoint (authenticated via HMAC body signature) and delivery
+	// log endpoints (authenticated via bearer token)
+	webhookHandler := s.webhooks
+	webhookAuth, err := middleware.WebhookAuth(middleware.WebhookAuthConfig{
+		Secret:    utils.GetEnvString("WEBHOOK_SECRET", ""),
+		Tolerance: time.Duration(utils.GetEnvInt("WEBHOOK_TOLERANCE_SECONDS", 300)) * time.Second,
+	}, appLogger)
+	if err != nil {
+		appLogger.Warn("Webhook endpoint disabled", "error", err)
+	}
+	webhookAdminAuth, adminErr := middleware.TokenAuth(utils.GetEnvString("WEBHOOK_ADMIN_TOKEN", ""), appLogger)
+	if adminErr != nil {
+		appLogger.Info("Webhook delivery endpoints disabled", "error", adminErr)
 	}
-
-	// Register routes
-	routeRegistry.RegisterRoutes(r, func(h http.Handler) http.Handler { return h })
-
-	// Feed routes
-	r.Get("/rss", feedHandler.RSS)
-	r.Get("/sitemap.xml", sitemapHandler.ServeHTTP)
-
-	// 404 handler
-	r.NotFound(notFoundHandler.ServeHTTP)
-
-	// Health endpoints
-	r.Get("/health/livez", healthHandler.Liveness)
-	r.Get("/health/readz", healthHandler.Readiness)
-
-	// Views endpoint (public, not cached)
-	r.Get("/api/posts/views/*", viewsHandler.GetSlug)
-
-	// Webhook endpoint (authenticated via X-Webhook-Secret header)
 	r.Route("/api/webhook", func(wr chi.Router) {
-		wr.Use(middleware.WebhookAuth(webhookSecret, appLogger))
-		wr.Post("/", webhookHandler.Handle)
+		if webhookAuth != nil {
+			wr.With(webhookAuth).Post("/", webhookHandler.Handle)
+		}
+		if webhookAdminAuth != nil {
+			wr.With(webhookAdminAuth).Get("/deliveries", webhookHandler.ListDeliveries)
+			wr.With(webhookAdminAuth).Post("/deliveries/replay", webhookHandler.ReplayDelivery)
+		}
 	})
 
 	// Set router on cache manager for revalidation
-	if cacheManager != nil {
-		cacheManager.SetRouter(r)
-	}
-
-	// Handle CLI commands (prerender, clear-cache, etc.)
-	if cli.ShouldRunCommand() && cacheManager != nil {
-		blogExpander := func(ctx context.Context, canonical string) ([]string, error) {
-			var paths []string
-			page := 1
-			const limit = 100
-			prefix := canonical[:strings.Index(canonical, "{")]
-			for {
-				resp, err := bloggoService.ListPosts(ctx, services.ListPostsParams{
-					Page:  page,
-					Limit: limit,
-				})
-				if err != nil {
-					return nil, err
-				}
-				for _, post := range resp.Data {
-					paths = append(paths, prefix+post.Slug)
-				}
-				if len(paths) >= resp.Total {
-					break
-				}
-				page++
-			}
-			return paths, nil
-		}
-
-		cliApp := cli.New()
-		cliApp.Register(cli.NewPrerenderCommand(cli.PrerenderCommandConfig{
-			ConfigFS:     configFS,
-			RoutesFile:   "routes.json",
-			Languages:    []string{"en"},
-			Router:       r,
-			CacheManager: cacheManager,
-			Logger:       appLogger,
-			PathExpander: blogExpander,
-		}))
-		cliApp.Register(cli.NewClearCacheCommand(cli.ClearCacheCommandConfig{
-			CacheDir: cacheDir,
-			Logger:   appLogger,
-		}))
-
-		if err := cliApp.Execute(os.Args[1:]); err != nil {
-			appLogger.Error("Command failed", "error", err)
-			os.Exit(1)
-		}
-		return
+	if s.cacheManager != nil {
+		s.cacheManager.SetRouter(r)
 	}
 
 	// Start server
@@ -321,10 +246,26 @@ func main() {
 		port = "8080"
 	}
 
-	if err := runServer(r, port, appLogger); err != nil {
-		appLogger.Error("Server error", "error", err)
-		os.Exit(1)
+	// Acknowledge webhooks immediately and process them in the background
+	webhookQueue := webhook.NewQueue(webhook.QueueConfig{
+		Workers:        utils.GetEnvInt("WEBHOOK_WORKERS", 2),
+		CoalesceWindow: time.Duration(utils.GetEnvInt("WEBHOOK_COALESCE_MS", 2000)) * time.Millisecond,
+		Capacity:       utils.GetEnvInt("WEBHOOK_QUEUE_CAPACITY", 1000),
+		MaxRetries:     utils.GetEnvInt("WEBHOOK_MAX_RETRIES", 3),
+		RetryDelay:     time.Second,
+	}, webhookHandler, appLogger)
+	webhookHandler.SetQueue(webhookQueue)
+
+	// Admin socket for the bans and webhooks commands
+	adminServer := security.NewAdminServer(a.adminSocket(), ipBanList, appLogger)
+	adminServer.Handle("GET /webhooks", http.HandlerFunc(webhookHandler.ListDeliveries))
+	adminServer.Handle("POST /webhooks/replay", http.HandlerFunc(webhookHandler.ReplayDelivery))
+	if err := adminServer.Start(); err != nil {
+		appLogger.Warn("Admin socket unavailable, bans commands will not reach this server", "error", err)
 	}
+	defer adminServer.Close()
+
+	return runServer(r, port, webhookQueue, appLogger)
 }
 
 // staticFileMiddleware serves static files from embedded filesystem
@@ -396,7 +337,7 @@ func staticFileMiddleware(staticFS fs.FS, httpFS http.FileSystem, minifier *util
 }
 
 // runServer starts the HTTP server with graceful shutdown
-func runServer(handler http.Handler, port string, log *slog.Logger) error {
+func runServer(handler http.Handler, port string, webhookQueue *webhook.Queue, log *slog.Logger) error {
 	shutdownTimeout := utils.GetEnvInt("SHUTDOWN_TIMEOUT", 30)
 
 	srv := &http.Server{
@@ -424,10 +365,21 @@ func runServer(handler http.Handler, port string, log *slog.Logger) error {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
 		defer cancel()
 
-		if err := srv.Shutdown(ctx); err != nil {
-			log.Error("Graceful shutdown failed", "error", err)
+		shutdownErr := srv.Shutdown(ctx)
+		if shutdownErr != nil {
+			log.Error("Graceful shutdown failed", "error", shutdownErr)
 			srv.Close()
-			return fmt.Errorf("shutdown error: %w", err)
+		}
+
+		// No new webhooks arrive once the server is down; finish queued ones
+		if err := webhookQueue.Drain(ctx); err != nil {
+			log.Error("Webhook queue not fully drained", "error", err)
+		} else {
+			log.Info("Webhook queue drained")
+		}
+
+		if shutdownErr != nil {
+			return fmt.Errorf("shutdown error: %w", shutdownErr)
 		}
 		log.Info("Server stopped gracefully")
 	}
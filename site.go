@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	chiMiddleware "github.com/go-chi/chi/middleware"
+
+	"statigo/framework/cache"
+	"statigo/framework/client"
+	"statigo/framework/dictionary"
+	"statigo/framework/health"
+	"statigo/framework/middleware"
+	"statigo/framework/router"
+	"statigo/framework/security"
+	"statigo/framework/templates"
+	"statigo/framework/utils"
+	"statigo/framework/webhook"
+	"statigo/internal/handlers"
+	"statigo/internal/services"
+)
+
+// app holds the settings shared by the server and the commands. The
+// expensive parts are built on first use, so each command sets up only what
+// it needs; the ban list, threat scoring and admin socket belong to the
+// server alone (see serve).
+type app struct {
+	logger   *slog.Logger
+	devMode  bool
+	baseURL  string
+	cacheDir string
+	dataDir  string // Persistent state lives next to the cache directory
+
+	content    *content
+	webhookLog *webhook.Log
+	site       *site
+}
+
+// content is the configured content source.
+type content struct {
+	source  services.ContentSource
+	bloggo  *services.BloggoService // nil unless the source is the Bloggo API
+	baseURL string                  // Prefix for cover image paths
+	check   health.CheckFunc        // nil when the source has no health check
+}
+
+// newApp reads the shared settings from the environment.
+func newApp(logger *slog.Logger) *app {
+	cacheDir := os.Getenv("CACHE_DIR")
+	if cacheDir == "" {
+		workDir, _ := os.Getwd()
+		cacheDir = filepath.Join(workDir, "data", "cache")
+	}
+
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &app{
+		logger:   logger,
+		devMode:  os.Getenv("DEV_MODE") == "true",
+		baseURL:  baseURL,
+		cacheDir: cacheDir,
+		dataDir:  filepath.Dir(cacheDir),
+	}
+}
+
+// usesBloggo reports whether content comes from the Bloggo API.
+func usesBloggo() bool {
+	return utils.GetEnvString("CONTENT_SOURCE", "bloggo") == "bloggo"
+}
+
+// ensureDataDir creates the directory holding persistent state.
+func (a *app) ensureDataDir() error {
+	if err := os.MkdirAll(a.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return nil
+}
+
+// banListFile returns the path of the persisted ban list.
+func (a *app) banListFile() string {
+	return filepath.Join(a.dataDir, "banned-ips.json")
+}
+
+// adminSocket returns the path of the admin socket of a running server.
+func (a *app) adminSocket() string {
+	return filepath.Join(a.dataDir, "admin.sock")
+}
+
+// trustedProxies returns the proxies allowed to set forwarding headers.
+func trustedProxies() []string {
+	return strings.Split(utils.GetEnvString("TRUSTED_PROXIES", "127.0.0.1,::1"), ",")
+}
+
+// banAllowlist returns the IPs and ranges that are never banned or scored.
+// Trusted proxies are always included: a request resolved to one of them
+// (e.g. a health check from the proxy itself) stands for every client
+// behind it.
+func banAllowlist() []string {
+	return append(strings.Split(utils.GetEnvString("BAN_ALLOWLIST", ""), ","), trustedProxies()...)
+}
+
+// contentSource sets up the content source on first use: the Bloggo API or
+// a directory of Markdown files.
+func (a *app) contentSource() (*content, error) {
+	if a.content != nil {
+		return a.content, nil
+	}
+
+	c := &content{}
+	switch source := utils.GetEnvString("CONTENT_SOURCE", "bloggo"); source {
+	case "bloggo":
+		bloggoAPIURL := utils.GetEnvString("BLOGGO_API_URL", "http://debian:8723")
+		bloggoAPIKey := utils.GetEnvString("BLOGGO_API_KEY", "")
+		bloggoClient := client.New(client.Config{
+			BaseURL:         bloggoAPIURL,
+			Timeout:         time.Duration(utils.GetEnvInt("HTTP_TIMEOUT", 30)) * time.Second,
+			ConnectTimeout:  time.Duration(utils.GetEnvInt("HTTP_CONNECT_TIMEOUT", 10)) * time.Second,
+			TLSTimeout:      time.Duration(utils.GetEnvInt("HTTP_TLS_TIMEOUT", 10)) * time.Second,
+			IdleConnTimeout: time.Duration(utils.GetEnvInt("HTTP_IDLE_TIMEOUT", 90)) * time.Second,
+			MaxRetries:      utils.GetEnvInt("HTTP_MAX_RETRIES", 3),
+			RetryWaitMin:    time.Duration(utils.GetEnvInt("HTTP_RETRY_BASE_DELAY", 500)) * time.Millisecond,
+			RetryWaitMax:    30 * time.Second,
+			UserAgent:       "Statigo/1.0",
+			Headers: map[string]string{
+				"x-trusted-frontend": bloggoAPIKey,
+			},
+			Breaker: client.BreakerConfig{
+				FailureThreshold:  utils.GetEnvInt("HTTP_BREAKER_THRESHOLD", 5),
+				CoolDown:          time.Duration(utils.GetEnvInt("HTTP_BREAKER_COOLDOWN", 30)) * time.Second,
+				HalfOpenSuccesses: utils.GetEnvInt("HTTP_BREAKER_HALF_OPEN_SUCCESSES", 1),
+			},
+		}, a.logger)
+		c.check = bloggoClient.HealthCheck("bloggo")
+		c.bloggo = services.NewBloggoService(bloggoClient, a.logger)
+		// Keep the last good responses to serve while the API is unreachable
+		if utils.GetEnvBool("BLOGGO_SNAPSHOT", true) {
+			snapshot, err := services.OpenSnapshot(utils.GetEnvString("BLOGGO_SNAPSHOT_DIR", filepath.Join(a.dataDir, "bloggo-snapshot")), a.logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open Bloggo snapshot: %w", err)
+			}
+			c.bloggo.SetSnapshot(snapshot)
+		}
+		c.source = c.bloggo
+		c.baseURL = bloggoAPIURL
+	case "markdown":
+		source, err := services.NewMarkdownSource(utils.GetEnvString("CONTENT_DIR", "./content"), a.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load markdown content: %w", err)
+		}
+		c.source = source
+	default:
+		return nil, fmt.Errorf("unknown content source %q", source)
+	}
+
+	a.content = c
+	return c, nil
+}
+
+// openWebhookLog opens the webhook delivery log on first use.
+func (a *app) openWebhookLog() (*webhook.Log, error) {
+	if a.webhookLog != nil {
+		return a.webhookLog, nil
+	}
+
+	log, err := webhook.OpenLog(filepath.Join(a.dataDir, "webhook-deliveries.jsonl"), utils.GetEnvInt("WEBHOOK_LOG_SIZE", 500), a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook delivery log: %w", err)
+	}
+	a.webhookLog = log
+	return log, nil
+}
+
+// site is everything needed to render pages: templates, routes, the content
+// source and the handlers built on them.
+type site struct {
+	app             *app
+	dict            *dictionary.Dictionary
+	routes          *router.Registry
+	cacheManager    *cache.Manager // nil in dev mode
+	content         *content
+	health          *health.Handler
+	views           *handlers.ViewsHandler
+	blogPost        *handlers.BlogPostHandler
+	feed            *handlers.FeedHandler
+	sitemap         *handlers.SitemapHandler
+	notFound        *handlers.NotFoundHandler
+	redirects       *middleware.RedirectRegistry
+	webhooks        *handlers.WebhookHandler
+	clientIP        *security.ClientIPResolver
+	securityHeaders middleware.SecurityHeadersConfig
+}
+
+// buildSite sets up the site on first use.
+func (a *app) buildSite() (*site, error) {
+	if a.site != nil {
+		return a.site, nil
+	}
+
+	// Get embedded filesystems
+	translationsFS := GetTranslationsFS()
+	templatesFS := GetTemplatesFS()
+	configFS := GetConfigFS()
+
+	// Initialize dictionary
+	dict, err := dictionary.New(translationsFS, "en")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize dictionary: %w", err)
+	}
+
+	// Initialize routing system
+	routeRegistry := router.NewRegistry()
+
+	// Initialize SEO helpers
+	seoHelpers := router.NewSEOHelpers(routeRegistry, a.baseURL)
+	routerSEOFuncs := seoHelpers.ToTemplateFunctions()
+
+	// Convert to templates.SEOFunctions (same structure, different package)
+	seoFuncs := &templates.SEOFunctions{
+		CanonicalURL:   routerSEOFuncs.CanonicalURL,
+		AlternateLinks: routerSEOFuncs.AlternateLinks,
+		AlternateURLs:  routerSEOFuncs.AlternateURLs,
+		LocalePath:     routerSEOFuncs.LocalePath,
+	}
+
+	// Initialize template renderer
+	renderer, err := templates.NewRenderer(templatesFS, dict, seoFuncs, a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize template renderer: %w", err)
+	}
+
+	// Initialize cache manager (skip in dev mode)
+	var cacheManager *cache.Manager
+	if !a.devMode {
+		cacheManager, err = cache.NewManager(a.cacheDir, a.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize cache manager: %w", err)
+		}
+		a.logger.Info("Cache manager initialized", "dir", a.cacheDir)
+	} else {
+		a.logger.Info("Disk cache disabled in dev mode")
+	}
+
+	if err := a.ensureDataDir(); err != nil {
+		return nil, err
+	}
+
+	// Initialize health check handler
+	healthHandler := health.NewHandler(5 * time.Second)
+
+	content, err := a.contentSource()
+	if err != nil {
+		return nil, err
+	}
+	if content.check != nil {
+		healthHandler.AddCheck(content.check)
+	}
+
+	// View counts are only available from sources that track them
+	viewCounter, _ := content.source.(services.ViewCounter)
+	viewsHandler := handlers.NewViewsHandler(viewCounter, 10*time.Minute)
+	viewTracker := services.NewViewTracker(a.logger)
+
+	// Initialize runtime redirect store (slug changes from webhooks)
+	redirectStore, err := middleware.NewRedirectStore(filepath.Join(a.dataDir, "redirects.json"), a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize redirect store: %w", err)
+	}
+
+	// Initialize webhook handler
+	webhookHandler := handlers.NewWebhookHandler(cacheManager, viewsHandler, redirectStore, a.logger)
+	webhookLog, err := a.openWebhookLog()
+	if err != nil {
+		return nil, err
+	}
+	webhookHandler.SetDeliveryLog(webhookLog)
+
+	// Initialize handlers
+	indexHandler := handlers.NewIndexHandler(renderer)
+	aboutHandler := handlers.NewAboutHandler(renderer)
+	blogsHandler := handlers.NewBlogsHandler(renderer, content.source, content.baseURL)
+	blogPostHandler := handlers.NewBlogPostHandler(renderer, content.source, content.baseURL, viewTracker, viewsHandler)
+
+	// Create custom handlers map for route loader
+	customHandlers := map[string]http.HandlerFunc{
+		"index":    indexHandler.ServeHTTP,
+		"blogs":    blogsHandler.ServeHTTP,
+		"about":    aboutHandler.ServeHTTP,
+		"blogpost": blogPostHandler.ServeHTTP,
+	}
+
+	// Load routes from JSON configuration
+	if err := router.LoadRoutesFromJSON(
+		configFS,
+		"routes.json",
+		routeRegistry,
+		renderer,
+		customHandlers,
+		a.logger,
+	); err != nil {
+		return nil, fmt.Errorf("failed to load routes: %w", err)
+	}
+
+	// Load redirects
+	redirectRegistry, err := middleware.LoadRedirectsFromJSON(configFS, "redirects.json", a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load redirects: %w", err)
+	}
+	redirectRegistry.SetStore(redirectStore)
+
+	// Client IP resolution (forwarding headers are only trusted from these proxies)
+	clientIPResolver, err := security.NewClientIPResolver(security.ClientIPConfig{
+		TrustedProxies: trustedProxies(),
+		UseForwarded:   utils.GetEnvBool("TRUST_FORWARDED_HEADER", false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure trusted proxies: %w", err)
+	}
+
+	// Content Security Policy (inline scripts are tagged with {{cspNonce}};
+	// the hashes allow the onload handler of the async stylesheet links, as
+	// written in base.html and as rewritten by the HTML minifier)
+	csp := security.DefaultCSPConfig()
+	csp.ScriptSrc = []string{
+		security.CSPSelf,
+		security.CSPUnsafeHashes,
+		security.CSPHash(`this.media='all'`),
+		security.CSPHash(`this.media="all"`),
+	}
+	csp.StyleSrc = []string{security.CSPSelf, "https://fonts.googleapis.com", "https://cdn.jsdelivr.net"}
+	csp.StyleSrcAttr = []string{security.CSPUnsafeInline}
+	csp.FontSrc = []string{security.CSPSelf, "https://fonts.gstatic.com", "https://cdn.jsdelivr.net"}
+	if u, err := url.Parse(content.baseURL); err == nil && u.Host != "" {
+		csp.ImgSrc = append(csp.ImgSrc, u.Scheme+"://"+u.Host)
+	}
+	csp.ReportOnly = utils.GetEnvBool("CSP_REPORT_ONLY", true)
+	csp.ReportURI = "/csp-report"
+
+	// Security headers (an empty value removes the header)
+	securityDefaults := middleware.DefaultSecurityHeadersConfig()
+	securityHeaders := middleware.SecurityHeadersConfig{
+		HSTSMaxAge:                utils.GetEnvInt("HSTS_MAX_AGE", securityDefaults.HSTSMaxAge),
+		HSTSIncludeSubDomains:     utils.GetEnvBool("HSTS_INCLUDE_SUBDOMAINS", securityDefaults.HSTSIncludeSubDomains),
+		HSTSPreload:               utils.GetEnvBool("HSTS_PRELOAD", securityDefaults.HSTSPreload),
+		FrameOptions:              utils.LookupEnvString("FRAME_OPTIONS", securityDefaults.FrameOptions),
+		ContentTypeOptions:        securityDefaults.ContentTypeOptions,
+		ReferrerPolicy:            utils.LookupEnvString("REFERRER_POLICY", securityDefaults.ReferrerPolicy),
+		PermissionsPolicy:         utils.LookupEnvString("PERMISSIONS_POLICY", securityDefaults.PermissionsPolicy),
+		CrossOriginOpenerPolicy:   utils.LookupEnvString("CROSS_ORIGIN_OPENER_POLICY", securityDefaults.CrossOriginOpenerPolicy),
+		CrossOriginEmbedderPolicy: utils.LookupEnvString("CROSS_ORIGIN_EMBEDDER_POLICY", securityDefaults.CrossOriginEmbedderPolicy),
+		CrossOriginResourcePolicy: utils.LookupEnvString("CROSS_ORIGIN_RESOURCE_POLICY", securityDefaults.CrossOriginResourcePolicy),
+		CSP:                       &csp,
+		RouteHeaders: func(path string) (string, middleware.RouteSecurityHeaders, bool) {
+			route := routeRegistry.Lookup(path)
+			if route == nil || route.Headers == nil {
+				return "", middleware.RouteSecurityHeaders{}, false
+			}
+			return route.Path, middleware.RouteSecurityHeaders(*route.Headers), true
+		},
+	}
+
+	a.site = &site{
+		app:             a,
+		dict:            dict,
+		routes:          routeRegistry,
+		cacheManager:    cacheManager,
+		content:         content,
+		health:          healthHandler,
+		views:           viewsHandler,
+		blogPost:        blogPostHandler,
+		feed:            handlers.NewFeedHandler(content.source, content.baseURL, a.baseURL),
+		sitemap:         handlers.NewSitemapHandler(content.source, a.baseURL),
+		notFound:        handlers.NewNotFoundHandler(renderer),
+		redirects:       redirectRegistry,
+		webhooks:        webhookHandler,
+		clientIP:        clientIPResolver,
+		securityHeaders: securityHeaders,
+	}
+	return a.site, nil
+}
+
+// routerOptions selects the parts of the router that differ between the
+// server and the commands that render through it.
+type routerOptions struct {
+	guards    []func(http.Handler) http.Handler // Server-only request filtering: bans, honeypots, threat scoring, rate limits
+	diskCache bool                              // Serve and store pages through the disk cache
+}
+
+// router builds the page router. Routes that only the server needs, such as
+// the webhook endpoints, are added by the caller.
+func (s *site) router(opts routerOptions) chi.Router {
+	r := chi.NewRouter()
+
+	// Apply middleware
+	r.Use(middleware.ClientIP(s.clientIP))
+	r.Use(middleware.StructuredLogger(s.app.logger))
+	r.Use(chiMiddleware.Recoverer)
+	r.Use(middleware.Degraded())
+	r.Use(opts.guards...)
+	r.Use(middleware.RedirectMiddleware(s.redirects, s.app.logger))
+	r.Use(middleware.Compression())
+	r.Use(middleware.SecurityHeaders(s.securityHeaders))
+	r.Use(middleware.CachingHeaders(s.app.devMode))
+
+	// Static file serving middleware
+	staticFS := GetStaticFS()
+	r.Use(staticFileMiddleware(staticFS, http.FS(staticFS), utils.NewMinifier()))
+
+	// Language middleware
+	langConfig := middleware.LanguageConfig{
+		SkipPaths:    []string{"/robots.txt", "/sitemap.xml", "/favicon.ico", "/csp-report"},
+		SkipPrefixes: []string{"/health/", "/static/", "/styles/", "/scripts/"},
+	}
+	r.Use(middleware.Language(s.dict, langConfig))
+
+	// Canonical path middleware
+	r.Use(router.CanonicalPathMiddleware(s.routes))
+
+	// View tracking middleware (runs before cache, so views are counted on cache hits too)
+	r.Use(s.blogPost.ViewTrackingMiddleware)
+
+	// Cache middleware (skip disk cache in dev mode)
+	if opts.diskCache && s.cacheManager != nil {
+		r.Use(middleware.CacheMiddlewareWithConfig(s.cacheManager, middleware.CacheConfig{
+			MaxStaleAge: time.Duration(utils.GetEnvInt("CACHE_MAX_STALE_HOURS", 168)) * time.Hour,
+		}, s.app.logger))
+	}
+
+	// Register routes
+	s.routes.RegisterRoutes(r, func(h http.Handler) http.Handler { return h })
+
+	// Feed routes
+	r.Get("/rss", s.feed.RSS)
+	r.Get("/sitemap.xml", s.sitemap.ServeHTTP)
+
+	// 404 handler
+	r.NotFound(s.notFound.ServeHTTP)
+
+	// Health endpoints
+	r.Get("/health/livez", s.health.Liveness)
+	r.Get("/health/readz", s.health.Readiness)
+
+	// CSP violation reports
+	r.Post("/csp-report", middleware.CSPReportHandler(s.app.logger))
+
+	// Views endpoint (public, not cached)
+	r.Get("/api/posts/views/*", s.views.GetSlug)
+
+	return r
+}
+
+// expandPosts lists the paths of all posts for a canonical route with a
+// slug parameter, e.g. "/blog/{slug}".
+func (s *site) expandPosts(ctx context.Context, canonical string) ([]string, error) {
+	var paths []string
+	page := 1
+	const limit = 100
+	prefix := canonical[:strings.Index(canonical, "{")]
+	for {
+		resp, err := s.content.source.ListPosts(ctx, services.ListPostsParams{
+			Page:  page,
+			Limit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range resp.Data {
+			paths = append(paths, prefix+post.Slug)
+		}
+		// An empty page ends the listing even if the total promised more
+		if len(resp.Data) == 0 || len(paths) >= resp.Total {
+			break
+		}
+		page++
+	}
+	return paths, nil
+}